@@ -0,0 +1,106 @@
+package activity
+
+import (
+	"context"
+	"fmt"
+	"github.com/tianlin0/plat-lib/logs"
+	"github.com/tianlin0/temporal/propagator"
+	temporalActivity "go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/log"
+	"strings"
+)
+
+const (
+	DslNameHeader = "dsl-name" //activity的header中记录流程定义的名字，日志和指标使用
+
+	LogKeyDslName    = "DslName"
+	LogKeyActivityId = "ActivityID" //与temporal日志中的字段名相同
+	LogKeyLogId      = "LogId"
+)
+
+// temporalLogger 将temporal的 log.Logger 适配为 logs.ILogger
+type temporalLogger struct {
+	logger log.Logger
+	level  logs.LogLevel
+	logId  string
+}
+
+// NewLogger 将temporal的 log.Logger 适配为 logs.ILogger，logId 不为空时增加 LogId 字段
+func NewLogger(logger log.Logger, logId string) logs.ILogger {
+	if logId != "" {
+		logger = log.With(logger, LogKeyLogId, logId)
+	}
+	return &temporalLogger{
+		logger: logger,
+		level:  logs.DEBUG,
+		logId:  logId,
+	}
+}
+
+// GetLogger activity中使用的日志，通过 activity.GetLogger 打印
+// temporal的日志已经带上 activity.GetInfo 中的流程id、run id、activity id，这里增加流程定义的名字和 logId
+// 不在activity中时使用 logs.CtxLogger
+func GetLogger(ctx context.Context) logs.ILogger {
+	if ctx == nil || !temporalActivity.IsActivity(ctx) {
+		return logs.CtxLogger(ctx)
+	}
+	logger := temporalActivity.GetLogger(ctx)
+	if dslName := getDslName(ctx); dslName != "" {
+		logger = log.With(logger, LogKeyDslName, dslName)
+	}
+	return NewLogger(logger, propagator.GetLogId(ctx))
+}
+
+type dslNameContextKey struct{}
+
+// WithDslName 在activity的ctx中设置流程定义的名字，由拦截器从header中读取后设置
+func WithDslName(ctx context.Context, dslName string) context.Context {
+	return context.WithValue(ctx, dslNameContextKey{}, dslName)
+}
+
+// getDslName 获取流程定义的名字
+func getDslName(ctx context.Context) string {
+	dslName, _ := ctx.Value(dslNameContextKey{}).(string)
+	return dslName
+}
+
+// getMessage 与 plat-lib 的日志一样，多个参数用空格连接
+func getMessage(v []interface{}) string {
+	return strings.TrimSuffix(fmt.Sprintln(v...), "\n")
+}
+
+func (l *temporalLogger) Debug(v ...interface{}) {
+	if l.level <= logs.DEBUG {
+		l.logger.Debug(getMessage(v))
+	}
+}
+
+func (l *temporalLogger) Info(v ...interface{}) {
+	if l.level <= logs.INFO {
+		l.logger.Info(getMessage(v))
+	}
+}
+
+func (l *temporalLogger) Warn(v ...interface{}) {
+	if l.level <= logs.WARNING {
+		l.logger.Warn(getMessage(v))
+	}
+}
+
+func (l *temporalLogger) Error(v ...interface{}) {
+	if l.level <= logs.ERROR {
+		l.logger.Error(getMessage(v))
+	}
+}
+
+func (l *temporalLogger) Level() logs.LogLevel {
+	return l.level
+}
+
+func (l *temporalLogger) SetLevel(level logs.LogLevel) {
+	l.level = level
+}
+
+func (l *temporalLogger) LogId(_ context.Context) string {
+	return l.logId
+}
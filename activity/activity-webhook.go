@@ -0,0 +1,112 @@
+package activity
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/tianlin0/plat-lib/conv"
+	"github.com/tianlin0/temporal/tracing"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	NotifyWebhookTemplate = "dsl-notify-webhook" //流程结束时发送通知的内置activity
+
+	WebhookSignatureHeader = "X-Dsl-Signature" //签名：sha256=hex(hmac_sha256(secret, timestamp + "." + body))
+	WebhookTimestampHeader = "X-Dsl-Timestamp" //签名使用的时间戳，秒
+
+	webhookTimeout = 10 * time.Second
+)
+
+// WebhookRequest 发送通知的参数
+type WebhookRequest struct {
+	Url       string                 `json:"url"`
+	SecretEnv string                 `json:"secretEnv,omitempty"` //签名密钥所在的环境变量名，在worker上读取，避免密钥写入流程历史
+	Headers   map[string]string      `json:"headers,omitempty"`
+	Payload   map[string]interface{} `json:"payload"`
+}
+
+// SignWebhook 计算通知的签名，接收方可以用同样的方法校验
+func SignWebhook(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+type webhookActivity struct {
+}
+
+// NewWebhookActivity 发送流程结束通知的内置activity，worker注册activity时会自动注册
+func NewWebhookActivity() TemplateActivity {
+	return new(webhookActivity)
+}
+
+func (w *webhookActivity) Template() string {
+	return NotifyWebhookTemplate
+}
+
+func (w *webhookActivity) GetMethod() TemplateMethod {
+	return w.execute
+}
+
+// execute 发送通知，返回非2xx时报错，由activity的重试策略进行重试
+func (w *webhookActivity) execute(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+	req := new(WebhookRequest)
+	if err := conv.Unmarshal(param, req); err != nil {
+		return nil, err
+	}
+	if req.Url == "" {
+		return nil, fmt.Errorf("webhook url is empty")
+	}
+
+	body, err := json.Marshal(req.Payload)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, req.Url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	for key, val := range req.Headers {
+		httpReq.Header.Set(key, val)
+	}
+	tracing.InjectHTTPHeader(ctx, httpReq.Header)
+	if req.SecretEnv != "" {
+		secret := os.Getenv(req.SecretEnv)
+		if secret == "" {
+			return nil, fmt.Errorf("webhook secret env %s is empty", req.SecretEnv)
+		}
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		httpReq.Header.Set(WebhookTimestampHeader, timestamp)
+		httpReq.Header.Set(WebhookSignatureHeader, SignWebhook(secret, timestamp, body))
+	}
+
+	httpClient := &http.Client{Timeout: webhookTimeout}
+	resp, err := httpClient.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	GetLogger(ctx).Info("webhook notify:", req.Url, resp.StatusCode, string(respBody))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("webhook %s return status: %d", req.Url, resp.StatusCode)
+	}
+	return map[string]interface{}{
+		"status": resp.StatusCode,
+	}, nil
+}
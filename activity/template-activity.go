@@ -9,6 +9,7 @@ import (
 	"github.com/tianlin0/plat-lib/logs"
 	"github.com/tianlin0/plat-lib/utils"
 	"github.com/tianlin0/plat-lib/utils/httputil"
+	"github.com/tianlin0/temporal/tracing"
 	"go.temporal.io/api/workflowservice/v1"
 	temporalActivity "go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/client"
@@ -247,10 +248,19 @@ func CurlActivityExecute(ctx context.Context, curlReq *curl.Request) (*curl.Resp
 		return nil, fmt.Errorf("data error")
 	}
 
+	//带上 trace 上下文，不修改调用方的header
+	newReq := *curlReq
+	newReq.Header = curlReq.Header.Clone()
+	if newReq.Header == nil {
+		newReq.Header = http.Header{}
+	}
+	tracing.InjectHTTPHeader(ctx, newReq.Header)
+	curlReq = &newReq
+
 	var resp *curl.Response
 	var err error
 	goroutines.GoSyncHandler(func(params ...interface{}) {
-		logger := logs.DefaultLogger()
+		logger := GetLogger(ctx)
 		logger.Info("CurlActivityExecute begin:", conv.String(curlReq))
 		resp = curl.NewRequest(curlReq).Submit(ctx)
 		logger.Info("CurlActivityExecute end:", resp.Error, resp.HttpStatus, resp.Response)
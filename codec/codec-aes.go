@@ -0,0 +1,118 @@
+package codec
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/proto"
+	"io"
+)
+
+const (
+	EncodingEncrypted = "binary/encrypted" //加密后的payload的encoding
+	MetadataKeyId     = "encryption-key-id"
+)
+
+// AESOptions AES-GCM加密的配置
+// 使用 KeyId 对应的密钥加密，解密时根据payload中记录的密钥id选择密钥
+// 轮换密钥时新增一个密钥并修改 KeyId，旧的密钥保留用于解密历史数据
+type AESOptions struct {
+	KeyId string            //当前加密使用的密钥id
+	Keys  map[string][]byte //所有密钥，长度为16、24或32字节，对应AES-128、AES-192、AES-256
+}
+
+type aesCodec struct {
+	keyId string
+	aeads map[string]cipher.AEAD
+}
+
+// NewAESCodec 新建AES-GCM加密的 PayloadCodec
+func NewAESCodec(opts *AESOptions) (converter.PayloadCodec, error) {
+	if opts == nil || opts.KeyId == "" {
+		return nil, fmt.Errorf("aes codec keyId is empty")
+	}
+	if _, ok := opts.Keys[opts.KeyId]; !ok {
+		return nil, fmt.Errorf("aes codec key %s not found", opts.KeyId)
+	}
+	c := &aesCodec{
+		keyId: opts.KeyId,
+		aeads: make(map[string]cipher.AEAD, len(opts.Keys)),
+	}
+	for id, key := range opts.Keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("aes codec key %s error: %s", id, err.Error())
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		c.aeads[id] = aead
+	}
+	return c, nil
+}
+
+// Encode 将整个payload序列化后加密，原来的metadata也一起加密
+func (c *aesCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	aead := c.aeads[c.keyId]
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		data, err := proto.Marshal(p)
+		if err != nil {
+			return payloads, err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+			return payloads, err
+		}
+		result[i] = &commonpb.Payload{
+			Metadata: map[string][]byte{
+				converter.MetadataEncoding: []byte(EncodingEncrypted),
+				MetadataKeyId:              []byte(c.keyId),
+			},
+			Data: aead.Seal(nonce, nonce, data, []byte(c.keyId)),
+		}
+	}
+	return result, nil
+}
+
+// Decode 只解密本codec加密的payload，其他的原样返回
+func (c *aesCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.GetMetadata()[converter.MetadataEncoding]) != EncodingEncrypted {
+			result[i] = p
+			continue
+		}
+		keyId := string(p.GetMetadata()[MetadataKeyId])
+		aead, ok := c.aeads[keyId]
+		if !ok {
+			return payloads, fmt.Errorf("aes codec key %s not found", keyId)
+		}
+		if len(p.GetData()) < aead.NonceSize() {
+			return payloads, fmt.Errorf("aes codec payload is too short")
+		}
+		nonce, cipherText := p.GetData()[:aead.NonceSize()], p.GetData()[aead.NonceSize():]
+		data, err := aead.Open(nil, nonce, cipherText, []byte(keyId))
+		if err != nil {
+			return payloads, fmt.Errorf("aes codec decrypt with key %s error: %s", keyId, err.Error())
+		}
+		result[i] = &commonpb.Payload{}
+		if err = proto.Unmarshal(data, result[i]); err != nil {
+			return payloads, err
+		}
+	}
+	return result, nil
+}
+
+// NewDataConverter 使用codec的 DataConverter，没有codec时返回默认的
+// 不通过 starter 创建连接时，需要将它设置到 client.Options.DataConverter
+func NewDataConverter(codecs ...converter.PayloadCodec) converter.DataConverter {
+	if len(codecs) == 0 {
+		return converter.GetDefaultDataConverter()
+	}
+	return converter.NewCodecDataConverter(converter.GetDefaultDataConverter(), codecs...)
+}
@@ -0,0 +1,169 @@
+package codec
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/proto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	EncodingClaimCheck = "binary/claim-check" //payload存储在 BlobStore 中，历史中只记录key
+
+	defaultClaimCheckThreshold = 128 * 1024
+	claimCheckTimeout          = 30 * time.Second
+)
+
+// BlobStore 存储大payload的地方，worker和查询历史的一方需要能访问同一个存储
+// 本地文件使用 NewFileStore，也可以实现为S3等对象存储
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte) error
+	Get(ctx context.Context, key string) ([]byte, error)
+}
+
+// ClaimCheckOptions 大payload存储的配置
+type ClaimCheckOptions struct {
+	Store     BlobStore
+	Threshold int    //序列化后大于等于这个字节数的payload放到 Store 中，默认128KB
+	KeyPrefix string //存储的key的前缀，如按队列区分
+}
+
+type claimCheckCodec struct {
+	opts *ClaimCheckOptions
+}
+
+// NewClaimCheckCodec 新建claim-check的 PayloadCodec，大payload存储到 BlobStore，历史中只记录key
+// key为内容的sha256，相同的内容只存储一次，重试和重放时不会产生新的数据
+// 与压缩、加密一起使用时应该最后编码：codec.NewDataConverter(claimCheckCodec, aesCodec, gzipCodec)
+func NewClaimCheckCodec(opts *ClaimCheckOptions) (converter.PayloadCodec, error) {
+	if opts == nil || opts.Store == nil {
+		return nil, fmt.Errorf("claim check store is nil")
+	}
+	newOpts := *opts
+	if newOpts.Threshold <= 0 {
+		newOpts.Threshold = defaultClaimCheckThreshold
+	}
+	return &claimCheckCodec{opts: &newOpts}, nil
+}
+
+func (c *claimCheckCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		data, err := proto.Marshal(p)
+		if err != nil {
+			return payloads, err
+		}
+		if len(data) < c.opts.Threshold {
+			result[i] = p
+			continue
+		}
+		sum := sha256.Sum256(data)
+		key := c.opts.KeyPrefix + hex.EncodeToString(sum[:])
+
+		ctx, cancel := context.WithTimeout(context.Background(), claimCheckTimeout)
+		err = c.opts.Store.Put(ctx, key, data)
+		cancel()
+		if err != nil {
+			return payloads, fmt.Errorf("claim check put %s error: %s", key, err.Error())
+		}
+		result[i] = &commonpb.Payload{
+			Metadata: map[string][]byte{converter.MetadataEncoding: []byte(EncodingClaimCheck)},
+			Data:     []byte(key),
+		}
+	}
+	return result, nil
+}
+
+// Decode 从 BlobStore 中取回payload，并校验内容的sha256
+func (c *claimCheckCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.GetMetadata()[converter.MetadataEncoding]) != EncodingClaimCheck {
+			result[i] = p
+			continue
+		}
+		key := string(p.GetData())
+		ctx, cancel := context.WithTimeout(context.Background(), claimCheckTimeout)
+		data, err := c.opts.Store.Get(ctx, key)
+		cancel()
+		if err != nil {
+			return payloads, fmt.Errorf("claim check get %s error: %s", key, err.Error())
+		}
+		sum := sha256.Sum256(data)
+		if !strings.HasSuffix(key, hex.EncodeToString(sum[:])) {
+			return payloads, fmt.Errorf("claim check %s content mismatch", key)
+		}
+		result[i] = &commonpb.Payload{}
+		if err = proto.Unmarshal(data, result[i]); err != nil {
+			return payloads, err
+		}
+	}
+	return result, nil
+}
+
+type fileStore struct {
+	dir string
+}
+
+// NewFileStore 使用本地目录存储payload，多个worker时需要是共享的目录
+func NewFileStore(dir string) (BlobStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("file store dir is empty")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &fileStore{dir: dir}, nil
+}
+
+// getPath key对应的文件，不允许超出目录
+func (f *fileStore) getPath(key string) (string, error) {
+	path := filepath.Join(f.dir, filepath.FromSlash(key))
+	rel, err := filepath.Rel(f.dir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("file store key %s is invalid", key)
+	}
+	return path, nil
+}
+
+// Put 先写临时文件再重命名，避免读到写了一半的内容
+func (f *fileStore) Put(_ context.Context, key string, data []byte) error {
+	path, err := f.getPath(key)
+	if err != nil {
+		return err
+	}
+	if _, err = os.Stat(path); err == nil {
+		return nil
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmpFile.Write(data)
+	if closeErr := tmpFile.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+func (f *fileStore) Get(_ context.Context, key string) ([]byte, error) {
+	path, err := f.getPath(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(path)
+}
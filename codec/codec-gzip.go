@@ -0,0 +1,114 @@
+package codec
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/proto"
+	"io"
+)
+
+const (
+	EncodingGzip = "binary/gzip" //压缩后的payload的encoding
+
+	defaultGzipThreshold  = 4 * 1024
+	defaultGzipMaxDecoded = 64 * 1024 * 1024
+)
+
+// GzipOptions gzip压缩的配置
+type GzipOptions struct {
+	Threshold      int //序列化后大于等于该字节数的payload才压缩，小于等于0时默认4KB
+	MaxDecodedSize int //解压后的最大字节数，超过时返回错误，避免很小的payload解压出很大的内容，小于等于0时默认64MB
+}
+
+type gzipCodec struct {
+	threshold      int
+	maxDecodedSize int
+}
+
+// NewGzipCodec 新建gzip压缩的 PayloadCodec，序列化后大于等于 threshold 字节的payload才压缩，压缩后没有变小时保留原始内容
+// threshold 小于等于0时默认4KB
+// 与加密一起使用时需要先压缩再加密：codec.NewDataConverter(aesCodec, gzipCodec)，codec按从后往前的顺序编码
+func NewGzipCodec(threshold int) converter.PayloadCodec {
+	return NewGzipCodecWithOptions(&GzipOptions{Threshold: threshold})
+}
+
+// NewGzipCodecWithOptions 根据配置新建gzip压缩的 PayloadCodec，可以设置解压后的最大字节数
+func NewGzipCodecWithOptions(opts *GzipOptions) converter.PayloadCodec {
+	c := &gzipCodec{
+		threshold:      defaultGzipThreshold,
+		maxDecodedSize: defaultGzipMaxDecoded,
+	}
+	if opts != nil && opts.Threshold > 0 {
+		c.threshold = opts.Threshold
+	}
+	if opts != nil && opts.MaxDecodedSize > 0 {
+		c.maxDecodedSize = opts.MaxDecodedSize
+	}
+	return c
+}
+
+func (c *gzipCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		data, err := proto.Marshal(p)
+		if err != nil {
+			return payloads, err
+		}
+		if len(data) < c.threshold {
+			result[i] = p
+			continue
+		}
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		_, err = w.Write(data)
+		if closeErr := w.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return payloads, err
+		}
+		if buf.Len() >= len(data) {
+			result[i] = p
+			continue
+		}
+		result[i] = &commonpb.Payload{
+			Metadata: map[string][]byte{converter.MetadataEncoding: []byte(EncodingGzip)},
+			Data:     buf.Bytes(),
+		}
+	}
+	return result, nil
+}
+
+// Decode 只解压本codec压缩的payload，其他的原样返回
+func (c *gzipCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.GetMetadata()[converter.MetadataEncoding]) != EncodingGzip {
+			result[i] = p
+			continue
+		}
+		r, err := gzip.NewReader(bytes.NewReader(p.GetData()))
+		if err != nil {
+			return payloads, err
+		}
+		//多读一个字节判断是否超过限制
+		data, err := io.ReadAll(io.LimitReader(r, int64(c.maxDecodedSize)+1))
+		if err == nil && len(data) > c.maxDecodedSize {
+			err = fmt.Errorf("gzip payload exceeds max decoded size %d", c.maxDecodedSize)
+		}
+		if closeErr := r.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return payloads, err
+		}
+		result[i] = &commonpb.Payload{}
+		if err = proto.Unmarshal(data, result[i]); err != nil {
+			return payloads, err
+		}
+	}
+	return result, nil
+}
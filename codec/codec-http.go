@@ -0,0 +1,80 @@
+package codec
+
+import (
+	"fmt"
+	"go.temporal.io/sdk/converter"
+	"net/http"
+	"strings"
+)
+
+// HTTPHandlerOptions codec服务的配置，Temporal UI 和 CLI 通过它解码payload
+type HTTPHandlerOptions struct {
+	Codecs       []converter.PayloadCodec
+	Authorize    func(r *http.Request) error //必填，校验请求，返回错误时拒绝，/decode 可以解密历史中的内容，不能不校验
+	AllowOrigins []string                    //允许跨域的域名，Temporal UI 直接从浏览器访问时需要；* 允许所有来源，但不允许携带cookie等凭证
+}
+
+type httpHandler struct {
+	opts    *HTTPHandlerOptions
+	handler http.Handler
+}
+
+// NewHTTPHandler 新建codec服务，提供 /encode 和 /decode 接口
+// 例如：http.Handle("/codec/", codec.NewHTTPHandler(opts))，UI中配置的地址为 http://host/codec
+func NewHTTPHandler(opts *HTTPHandlerOptions) (http.Handler, error) {
+	if opts == nil || len(opts.Codecs) == 0 {
+		return nil, fmt.Errorf("codec http handler codecs is empty")
+	}
+	if opts.Authorize == nil {
+		return nil, fmt.Errorf("codec http handler authorize is empty")
+	}
+	return &httpHandler{
+		opts:    opts,
+		handler: converter.NewPayloadCodecHTTPHandler(opts.Codecs...),
+	}, nil
+}
+
+func (h *httpHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.setCorsHeaders(w, r)
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if err := h.opts.Authorize(r); err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
+// setCorsHeaders 来源在允许的列表中时设置跨域的header
+func (h *httpHandler) setCorsHeaders(w http.ResponseWriter, r *http.Request) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	allowAll := false
+	for _, one := range h.opts.AllowOrigins {
+		if one == "*" {
+			allowAll = true
+			continue
+		}
+		if strings.EqualFold(one, origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			w.Header().Add("Vary", "Origin")
+			setCorsMethodHeaders(w)
+			return
+		}
+	}
+	if allowAll {
+		//允许所有来源时不能携带凭证，否则任意网站都可以用用户的登录态解密
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		setCorsMethodHeaders(w)
+	}
+}
+
+func setCorsMethodHeaders(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Namespace")
+}
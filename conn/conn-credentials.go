@@ -0,0 +1,146 @@
+package conn
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/tianlin0/plat-lib/logs"
+	"go.temporal.io/sdk/client"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTokenRefreshInterval = time.Minute
+)
+
+// HeadersProvider 每次请求temporal时获取需要附加的header，与 client.Options 中的 HeadersProvider 相同
+type HeadersProvider interface {
+	GetHeaders(ctx context.Context) (map[string]string, error)
+}
+
+// CredentialOptions 连接temporal的认证配置，以 Authorization: Bearer 的方式传递
+type CredentialOptions struct {
+	APIKey               string          //固定的API key
+	TokenFile            string          //token所在的文件，定期重新读取，适用于token会轮换的场景
+	TokenRefreshInterval time.Duration   //重新读取token文件的间隔，默认1分钟
+	HeadersProvider      HeadersProvider //自定义每次请求的header
+}
+
+// isEmpty 没有设置任何认证
+func (o *CredentialOptions) isEmpty() bool {
+	if o == nil {
+		return true
+	}
+	return o.APIKey == "" && o.TokenFile == "" && o.HeadersProvider == nil
+}
+
+// fingerprint 认证配置的摘要，用于连接的缓存key，只记录摘要，不包含密钥本身
+func (o *CredentialOptions) fingerprint() string {
+	if o.isEmpty() {
+		return ""
+	}
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s|%s|%d|%s", o.APIKey, o.TokenFile, o.TokenRefreshInterval,
+		getInstanceId(o.HeadersProvider))
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// check APIKey 和 TokenFile 只能设置一个
+func (o *CredentialOptions) check() error {
+	if o.APIKey != "" && o.TokenFile != "" {
+		return fmt.Errorf("credentials apiKey and tokenFile can not be set together")
+	}
+	return nil
+}
+
+// setClientOptions 将认证配置设置到 client.Options
+func (o *CredentialOptions) setClientOptions(dialOption *client.Options) error {
+	if o.isEmpty() {
+		return nil
+	}
+	if err := o.check(); err != nil {
+		return err
+	}
+	if o.APIKey != "" {
+		dialOption.Credentials = client.NewAPIKeyStaticCredentials(o.APIKey)
+	} else if o.TokenFile != "" {
+		tokenFile, err := newTokenFileCredentials(o.TokenFile, o.TokenRefreshInterval)
+		if err != nil {
+			return err
+		}
+		dialOption.Credentials = client.NewAPIKeyDynamicCredentials(tokenFile.getToken)
+	}
+	if o.HeadersProvider != nil {
+		dialOption.HeadersProvider = o.HeadersProvider
+	}
+	return nil
+}
+
+// getInstanceId 区分不同的 HeadersProvider、PayloadCodec 等实例，指针类型使用地址
+func getInstanceId(instance interface{}) string {
+	if instance == nil {
+		return ""
+	}
+	val := reflect.ValueOf(instance)
+	switch val.Kind() {
+	case reflect.Ptr, reflect.Func, reflect.Map, reflect.Chan:
+		return fmt.Sprintf("%T:%x", instance, val.Pointer())
+	}
+	return fmt.Sprintf("%T:%v", instance, instance)
+}
+
+// tokenFileCredentials 定期从文件中读取token
+type tokenFileCredentials struct {
+	path     string
+	interval time.Duration
+
+	lock     sync.Mutex
+	token    string
+	loadTime time.Time
+}
+
+func newTokenFileCredentials(path string, interval time.Duration) (*tokenFileCredentials, error) {
+	if interval <= 0 {
+		interval = defaultTokenRefreshInterval
+	}
+	t := &tokenFileCredentials{
+		path:     path,
+		interval: interval,
+	}
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *tokenFileCredentials) reload() error {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		return err
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return fmt.Errorf("token file %s is empty", t.path)
+	}
+	t.token = token
+	t.loadTime = time.Now()
+	return nil
+}
+
+// getToken 超过刷新间隔时重新读取，读取失败时继续使用之前的token
+func (t *tokenFileCredentials) getToken(context.Context) (string, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if time.Since(t.loadTime) >= t.interval {
+		if err := t.reload(); err != nil {
+			logs.DefaultLogger().Error("reload token file error:", t.path, err)
+			t.loadTime = time.Now()
+		}
+	}
+	return t.token, nil
+}
@@ -0,0 +1,70 @@
+package conn
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCredentialsFingerprint(t *testing.T) {
+	first := (&CredentialOptions{APIKey: "key-1"}).fingerprint()
+	second := (&CredentialOptions{APIKey: "key-2"}).fingerprint()
+	if first == "" || first == second {
+		t.Fatalf("fingerprint should change with api key: %s %s", first, second)
+	}
+	if first != (&CredentialOptions{APIKey: "key-1"}).fingerprint() {
+		t.Fatal("fingerprint should be stable")
+	}
+	if strings.Contains(first, "key-1") {
+		t.Fatal("fingerprint contains api key")
+	}
+	tokenFirst := (&CredentialOptions{TokenFile: "/var/run/token-1"}).fingerprint()
+	tokenSecond := (&CredentialOptions{TokenFile: "/var/run/token-2"}).fingerprint()
+	if tokenFirst == tokenSecond || tokenFirst == first {
+		t.Fatalf("fingerprint should change with token file: %s %s", tokenFirst, tokenSecond)
+	}
+	if (*CredentialOptions)(nil).fingerprint() != "" {
+		t.Fatal("empty credentials should have no fingerprint")
+	}
+	if err := (&CredentialOptions{APIKey: "key-1", TokenFile: "/var/run/token"}).check(); err == nil {
+		t.Fatal("expected error for apiKey with tokenFile")
+	}
+}
+
+func TestTokenFileRefresh(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("token-1\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := newTokenFileCredentials(tokenPath, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := credentials.getToken(context.Background()); token != "token-1" {
+		t.Fatalf("unexpected token %s", token)
+	}
+
+	//token轮换后，刷新间隔内使用之前的token，超过间隔后重新读取
+	if err = os.WriteFile(tokenPath, []byte("token-2"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if token, _ := credentials.getToken(context.Background()); token != "token-1" {
+		t.Fatalf("token refreshed before interval: %s", token)
+	}
+	credentials.loadTime = time.Now().Add(-2 * time.Hour)
+	if token, _ := credentials.getToken(context.Background()); token != "token-2" {
+		t.Fatalf("rotated token not loaded: %s", token)
+	}
+
+	//读取失败时继续使用之前的token
+	if err = os.WriteFile(tokenPath, []byte(" "), 0600); err != nil {
+		t.Fatal(err)
+	}
+	credentials.loadTime = time.Now().Add(-2 * time.Hour)
+	if token, _ := credentials.getToken(context.Background()); token != "token-2" {
+		t.Fatalf("expected previous token kept: %s", token)
+	}
+}
@@ -0,0 +1,126 @@
+package conn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/tianlin0/plat-lib/logs"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/operatorservice/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"time"
+)
+
+const (
+	defaultNamespaceRetention = 72 * time.Hour
+	ensureNamespaceTimeout    = 30 * time.Second
+)
+
+// namespaceReadyInterval 注册后轮询 namespace 是否可见的间隔
+var namespaceReadyInterval = time.Second
+
+// NamespaceOptions 连接时检查namespace，不存在时自动创建，用于本地开发和测试环境
+type NamespaceOptions struct {
+	Retention        time.Duration                     //流程历史的保留时间，默认3天
+	Description      string                            //创建时的描述
+	SearchAttributes map[string]enums.IndexedValueType //需要的自定义搜索属性，不存在时添加
+}
+
+// ensureNamespace namespace 不存在时创建，并添加缺少的搜索属性
+func (o *NamespaceOptions) ensureNamespace(temporalClient client.Client, namespace string) error {
+	if o == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ensureNamespaceTimeout)
+	defer cancel()
+
+	_, err := temporalClient.WorkflowService().DescribeNamespace(ctx, &workflowservice.DescribeNamespaceRequest{
+		Namespace: namespace,
+	})
+	if err != nil {
+		var notFound *serviceerror.NamespaceNotFound
+		if !errors.As(err, &notFound) {
+			return err
+		}
+		retention := o.Retention
+		if retention <= 0 {
+			retention = defaultNamespaceRetention
+		}
+		_, err = temporalClient.WorkflowService().RegisterNamespace(ctx, &workflowservice.RegisterNamespaceRequest{
+			Namespace:                        namespace,
+			Description:                      o.Description,
+			WorkflowExecutionRetentionPeriod: durationpb.New(retention),
+		})
+		var exists *serviceerror.NamespaceAlreadyExists
+		if err != nil && !errors.As(err, &exists) {
+			return fmt.Errorf("register namespace %s error: %s", namespace, err.Error())
+		}
+		logs.DefaultLogger().Info("register namespace:", namespace, retention)
+		if err = waitNamespaceReady(ctx, temporalClient, namespace); err != nil {
+			return err
+		}
+	}
+
+	return o.ensureSearchAttributes(ctx, temporalClient, namespace)
+}
+
+// waitNamespaceReady 注册后 namespace 需要一段时间才能在各个服务节点上可见，轮询直到可见或超时
+func waitNamespaceReady(ctx context.Context, temporalClient client.Client, namespace string) error {
+	ticker := time.NewTicker(namespaceReadyInterval)
+	defer ticker.Stop()
+	for {
+		_, err := temporalClient.WorkflowService().DescribeNamespace(ctx, &workflowservice.DescribeNamespaceRequest{
+			Namespace: namespace,
+		})
+		if err == nil {
+			return nil
+		}
+		var notFound *serviceerror.NamespaceNotFound
+		if !errors.As(err, &notFound) {
+			return fmt.Errorf("describe namespace %s error: %s", namespace, err.Error())
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait namespace %s ready timeout: %s", namespace, ctx.Err().Error())
+		case <-ticker.C:
+		}
+	}
+}
+
+// ensureSearchAttributes 添加缺少的搜索属性，已存在的不修改
+func (o *NamespaceOptions) ensureSearchAttributes(ctx context.Context, temporalClient client.Client, namespace string) error {
+	if len(o.SearchAttributes) == 0 {
+		return nil
+	}
+	listResp, err := temporalClient.OperatorService().ListSearchAttributes(ctx, &operatorservice.ListSearchAttributesRequest{
+		Namespace: namespace,
+	})
+	if err != nil {
+		return err
+	}
+	addMap := make(map[string]enums.IndexedValueType)
+	for name, valueType := range o.SearchAttributes {
+		if _, ok := listResp.GetCustomAttributes()[name]; ok {
+			continue
+		}
+		if _, ok := listResp.GetSystemAttributes()[name]; ok {
+			continue
+		}
+		addMap[name] = valueType
+	}
+	if len(addMap) == 0 {
+		return nil
+	}
+	_, err = temporalClient.OperatorService().AddSearchAttributes(ctx, &operatorservice.AddSearchAttributesRequest{
+		SearchAttributes: addMap,
+		Namespace:        namespace,
+	})
+	if err != nil {
+		return fmt.Errorf("add search attributes to namespace %s error: %s", namespace, err.Error())
+	}
+	logs.DefaultLogger().Info("add search attributes:", namespace, addMap)
+	return nil
+}
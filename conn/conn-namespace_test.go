@@ -0,0 +1,92 @@
+package conn
+
+import (
+	"context"
+	"errors"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/mocks"
+	"google.golang.org/grpc"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testNamespaceService 注册后第 visibleAfter 次查询才可见，模拟 namespace 的传播延迟
+type testNamespaceService struct {
+	workflowservice.WorkflowServiceClient
+	lock         sync.Mutex
+	describes    int
+	registers    int
+	visibleAfter int
+	describeErr  error
+}
+
+func (s *testNamespaceService) DescribeNamespace(ctx context.Context, in *workflowservice.DescribeNamespaceRequest,
+	opts ...grpc.CallOption) (*workflowservice.DescribeNamespaceResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.describes++
+	if s.describeErr != nil && s.registers > 0 {
+		return nil, s.describeErr
+	}
+	if s.registers == 0 || s.describes <= s.visibleAfter {
+		return nil, serviceerror.NewNamespaceNotFound(in.GetNamespace())
+	}
+	return &workflowservice.DescribeNamespaceResponse{}, nil
+}
+
+func (s *testNamespaceService) RegisterNamespace(ctx context.Context, in *workflowservice.RegisterNamespaceRequest,
+	opts ...grpc.CallOption) (*workflowservice.RegisterNamespaceResponse, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.registers++
+	return &workflowservice.RegisterNamespaceResponse{}, nil
+}
+
+func TestEnsureNamespaceRegister(t *testing.T) {
+	oldInterval := namespaceReadyInterval
+	namespaceReadyInterval = 10 * time.Millisecond
+	defer func() { namespaceReadyInterval = oldInterval }()
+
+	service := &testNamespaceService{visibleAfter: 3}
+	c := &mocks.Client{}
+	c.On("WorkflowService").Return(service)
+
+	if err := (&NamespaceOptions{}).ensureNamespace(c, "test-ns"); err != nil {
+		t.Fatal(err)
+	}
+	if service.registers != 1 {
+		t.Fatalf("registers = %d, want 1", service.registers)
+	}
+	// 第1次查询不存在，注册后第2、3次仍不可见，第4次可见
+	if service.describes != 4 {
+		t.Fatalf("describes = %d, want 4", service.describes)
+	}
+
+	service = &testNamespaceService{describeErr: errors.New("unavailable")}
+	c = &mocks.Client{}
+	c.On("WorkflowService").Return(service)
+	if err := (&NamespaceOptions{}).ensureNamespace(c, "test-ns"); err == nil {
+		t.Fatal("expected describe error after register")
+	}
+}
+
+func TestWaitNamespaceReadyTimeout(t *testing.T) {
+	oldInterval := namespaceReadyInterval
+	namespaceReadyInterval = 10 * time.Millisecond
+	defer func() { namespaceReadyInterval = oldInterval }()
+
+	service := &testNamespaceService{registers: 1, visibleAfter: 1 << 30}
+	c := &mocks.Client{}
+	c.On("WorkflowService").Return(service)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := waitNamespaceReady(ctx, c, "test-ns"); err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if service.describes < 2 {
+		t.Fatalf("describes = %d, want polling", service.describes)
+	}
+}
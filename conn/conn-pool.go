@@ -0,0 +1,239 @@
+package conn
+
+import (
+	"context"
+	"github.com/tianlin0/plat-lib/logs"
+	"go.temporal.io/sdk/client"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHealthCheckInterval = 30 * time.Second
+	healthCheckTimeout         = 5 * time.Second
+	defaultRetireDelay         = 5 * time.Minute //被剔除的连接可能还在被使用，延迟关闭
+
+	MetricPoolHits                = "temporal_client_pool_hits"                  //命中缓存的次数
+	MetricPoolMisses              = "temporal_client_pool_misses"                //新建连接的次数
+	MetricPoolRedials             = "temporal_client_pool_redials"               //连接被剔除后重新建立的次数
+	MetricPoolEvictions           = "temporal_client_pool_evictions"             //健康检查失败被剔除的次数
+	MetricPoolHealthCheckFailures = "temporal_client_pool_health_check_failures" //健康检查失败的次数
+	MetricTagNamespace            = "namespace"
+)
+
+var (
+	defaultPool = newClientPool(defaultHealthCheckInterval)
+)
+
+// PoolStats 连接池的统计
+type PoolStats struct {
+	Clients             int   `json:"clients"`             //当前缓存的连接数
+	Hits                int64 `json:"hits"`                //命中缓存的次数
+	Misses              int64 `json:"misses"`              //新建连接的次数
+	Redials             int64 `json:"redials"`             //连接被剔除后重新建立的次数
+	Evictions           int64 `json:"evictions"`           //健康检查失败被剔除的次数
+	HealthCheckFailures int64 `json:"healthCheckFailures"` //健康检查失败的次数
+}
+
+type pooledClient struct {
+	client  client.Client
+	metrics client.MetricsHandler //连接配置的 MetricsHandler，记录连接池的指标
+}
+
+// incCounter 通过连接配置的 MetricsHandler 记录连接池的计数，没有配置时不记录
+func (pc *pooledClient) incCounter(name string) {
+	if pc.metrics != nil {
+		pc.metrics.Counter(name).Inc(1)
+	}
+}
+
+// clientPool 缓存temporal连接，定期检查连接是否可用，不可用时剔除，下次获取时重新建立
+type clientPool struct {
+	lock     sync.Mutex
+	clients  map[string]*pooledClient
+	evicted  map[string]bool               //被剔除过的key，再次建立连接时记为重连
+	retired  map[client.Client]*time.Timer //被剔除的连接，可能还在被worker使用，retireDelay 后关闭
+	interval time.Duration
+	// retireDelay 被剔除的连接延迟关闭的时间
+	retireDelay time.Duration
+	stopCh      chan struct{}
+	stats       PoolStats
+}
+
+func newClientPool(interval time.Duration) *clientPool {
+	return &clientPool{
+		clients:     make(map[string]*pooledClient),
+		evicted:     make(map[string]bool),
+		retired:     make(map[client.Client]*time.Timer),
+		interval:    interval,
+		retireDelay: defaultRetireDelay,
+	}
+}
+
+// get 获取缓存的连接，没有时使用 dial 新建，metrics 为连接配置的 MetricsHandler
+func (p *clientPool) get(key string, metrics client.MetricsHandler, dial func() (client.Client, error)) (client.Client, error) {
+	p.lock.Lock()
+	if pc, ok := p.clients[key]; ok {
+		p.stats.Hits++
+		p.lock.Unlock()
+		pc.incCounter(MetricPoolHits)
+		return pc.client, nil
+	}
+	p.lock.Unlock()
+
+	newClient, err := dial()
+	if err != nil {
+		return nil, err
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	//并发建立了相同的连接，使用先建立的
+	if pc, ok := p.clients[key]; ok {
+		p.stats.Hits++
+		newClient.Close()
+		pc.incCounter(MetricPoolHits)
+		return pc.client, nil
+	}
+	pc := &pooledClient{client: newClient, metrics: metrics}
+	p.stats.Misses++
+	pc.incCounter(MetricPoolMisses)
+	if p.evicted[key] {
+		p.stats.Redials++
+		pc.incCounter(MetricPoolRedials)
+		delete(p.evicted, key)
+	}
+	p.clients[key] = pc
+	p.startHealthCheck()
+	return newClient, nil
+}
+
+// startHealthCheck 启动定期的健康检查，需要持有锁
+func (p *clientPool) startHealthCheck() {
+	if p.stopCh != nil || p.interval <= 0 {
+		return
+	}
+	stopCh := make(chan struct{})
+	p.stopCh = stopCh
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopCh:
+				return
+			case <-ticker.C:
+				p.checkHealth()
+			}
+		}
+	}()
+}
+
+// checkHealth 检查所有连接，失败的从缓存中剔除
+func (p *clientPool) checkHealth() {
+	p.lock.Lock()
+	checkList := make(map[string]*pooledClient, len(p.clients))
+	for key, pc := range p.clients {
+		checkList[key] = pc
+	}
+	p.lock.Unlock()
+
+	for key, pc := range checkList {
+		ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+		_, err := pc.client.CheckHealth(ctx, &client.CheckHealthRequest{})
+		cancel()
+		if err == nil {
+			continue
+		}
+		logs.DefaultLogger().Error("temporal client health check error:", key, err)
+		p.evict(key, pc)
+	}
+}
+
+// evict 剔除连接，已经被其他连接替换时不处理
+func (p *clientPool) evict(key string, pc *pooledClient) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.stats.HealthCheckFailures++
+	pc.incCounter(MetricPoolHealthCheckFailures)
+	if p.clients[key] != pc {
+		return
+	}
+	delete(p.clients, key)
+	p.evicted[key] = true
+	p.stats.Evictions++
+	pc.incCounter(MetricPoolEvictions)
+	retiredClient := pc.client
+	p.retired[retiredClient] = time.AfterFunc(p.retireDelay, func() {
+		p.closeRetired(retiredClient)
+	})
+}
+
+// closeRetired 关闭被剔除的连接，已经被 CloseAll 关闭时不处理
+func (p *clientPool) closeRetired(c client.Client) {
+	p.lock.Lock()
+	if _, ok := p.retired[c]; !ok {
+		p.lock.Unlock()
+		return
+	}
+	delete(p.retired, c)
+	p.lock.Unlock()
+	c.Close()
+}
+
+// closeAll 关闭所有连接，停止健康检查
+func (p *clientPool) closeAll() {
+	p.lock.Lock()
+	closeList := make([]client.Client, 0, len(p.retired)+len(p.clients))
+	for c, timer := range p.retired {
+		timer.Stop()
+		closeList = append(closeList, c)
+	}
+	for _, pc := range p.clients {
+		closeList = append(closeList, pc.client)
+	}
+	p.clients = make(map[string]*pooledClient)
+	p.evicted = make(map[string]bool)
+	p.retired = make(map[client.Client]*time.Timer)
+	if p.stopCh != nil {
+		close(p.stopCh)
+		p.stopCh = nil
+	}
+	p.lock.Unlock()
+
+	for _, c := range closeList {
+		c.Close()
+	}
+}
+
+func (p *clientPool) getStats() PoolStats {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	stats := p.stats
+	stats.Clients = len(p.clients)
+	return stats
+}
+
+// CloseAll 关闭所有缓存的temporal连接，用于服务退出时，需要先停止worker
+func CloseAll() {
+	defaultPool.closeAll()
+}
+
+// GetPoolStats 获取连接池的统计，配置了 MetricsHandler 时同时作为指标记录
+func GetPoolStats() PoolStats {
+	return defaultPool.getStats()
+}
+
+// SetHealthCheckInterval 设置连接健康检查的间隔，小于等于0时不检查，需要在获取连接之前设置
+func SetHealthCheckInterval(interval time.Duration) {
+	defaultPool.lock.Lock()
+	defer defaultPool.lock.Unlock()
+	defaultPool.interval = interval
+}
+
+// SetRetireDelay 设置被剔除的连接延迟关闭的时间，需要大于worker停止使用旧连接的时间
+func SetRetireDelay(delay time.Duration) {
+	defaultPool.lock.Lock()
+	defer defaultPool.lock.Unlock()
+	defaultPool.retireDelay = delay
+}
@@ -0,0 +1,127 @@
+package conn
+
+import (
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testMetricsHandler 记录计数类的指标
+type testMetricsHandler struct {
+	client.MetricsHandler
+	lock   sync.Mutex
+	counts map[string]int64
+}
+
+type testCounter struct {
+	h    *testMetricsHandler
+	name string
+}
+
+func (c *testCounter) Inc(delta int64) {
+	c.h.lock.Lock()
+	defer c.h.lock.Unlock()
+	c.h.counts[c.name] += delta
+}
+
+func (h *testMetricsHandler) WithTags(map[string]string) client.MetricsHandler {
+	return h
+}
+
+func (h *testMetricsHandler) Counter(name string) client.MetricsCounter {
+	return &testCounter{h: h, name: name}
+}
+
+func (h *testMetricsHandler) get(name string) int64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.counts[name]
+}
+
+func TestClientPoolEvictAndRedial(t *testing.T) {
+	p := newClientPool(0)
+	p.retireDelay = 10 * time.Millisecond
+	handler := &testMetricsHandler{MetricsHandler: client.MetricsNopHandler, counts: make(map[string]int64)}
+
+	badClosed := make(chan struct{})
+	bad := &mocks.Client{}
+	bad.On("CheckHealth", mock.Anything, mock.Anything).Return(nil, errors.New("frontend restarted"))
+	bad.On("Close").Run(func(mock.Arguments) { close(badClosed) }).Return()
+	good := &mocks.Client{}
+	good.On("CheckHealth", mock.Anything, mock.Anything).Return(&client.CheckHealthResponse{}, nil)
+	good.On("Close").Return()
+
+	dialList := []client.Client{bad, good}
+	dial := func() (client.Client, error) {
+		c := dialList[0]
+		dialList = dialList[1:]
+		return c, nil
+	}
+
+	first, err := p.get("key", handler, dial)
+	if err != nil || first != bad {
+		t.Fatalf("unexpected first client: %v", err)
+	}
+	if cached, _ := p.get("key", handler, dial); cached != bad {
+		t.Fatal("expected cached client")
+	}
+
+	//健康检查失败后剔除，下次获取时重新建立
+	p.checkHealth()
+	redialed, err := p.get("key", handler, dial)
+	if err != nil || redialed != good {
+		t.Fatalf("expected redialed client: %v", err)
+	}
+	p.checkHealth()
+
+	stats := p.getStats()
+	if stats.Clients != 1 || stats.Hits != 1 || stats.Misses != 2 || stats.Redials != 1 || stats.Evictions != 1 ||
+		stats.HealthCheckFailures != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	expected := map[string]int64{
+		MetricPoolHits:                1,
+		MetricPoolMisses:              2,
+		MetricPoolRedials:             1,
+		MetricPoolEvictions:           1,
+		MetricPoolHealthCheckFailures: 1,
+	}
+	for name, val := range expected {
+		if got := handler.get(name); got != val {
+			t.Fatalf("metric %s = %d, want %d", name, got, val)
+		}
+	}
+
+	//被剔除的连接延迟后关闭
+	select {
+	case <-badClosed:
+	case <-time.After(time.Second):
+		t.Fatal("retired client not closed")
+	}
+	good.AssertNotCalled(t, "Close")
+
+	p.closeAll()
+	good.AssertCalled(t, "Close")
+	if stats = p.getStats(); stats.Clients != 0 {
+		t.Fatalf("unexpected stats after close: %+v", stats)
+	}
+}
+
+func TestClientPoolCloseAllClosesRetired(t *testing.T) {
+	p := newClientPool(0)
+	p.retireDelay = time.Hour
+	bad := &mocks.Client{}
+	bad.On("CheckHealth", mock.Anything, mock.Anything).Return(nil, errors.New("frontend restarted"))
+	bad.On("Close").Return()
+	if _, err := p.get("key", nil, func() (client.Client, error) { return bad, nil }); err != nil {
+		t.Fatal(err)
+	}
+	p.checkHealth()
+	bad.AssertNotCalled(t, "Close")
+	p.closeAll()
+	bad.AssertNumberOfCalls(t, "Close", 1)
+}
@@ -0,0 +1,139 @@
+package conn
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"github.com/tianlin0/plat-lib/logs"
+	"os"
+	"sync"
+	"time"
+)
+
+// TLSOptions 连接temporal的TLS配置
+type TLSOptions struct {
+	CertPath           string //客户端证书，与 KeyPath 同时设置时使用双向认证，文件更新后自动重新加载
+	KeyPath            string
+	CAPath             string //CA证书，为空时使用系统的CA
+	ServerName         string //校验服务端证书的域名，为空时使用连接的host
+	MinVersion         uint16 //最低TLS版本，如 tls.VersionTLS12，为空时默认 TLS1.2
+	InsecureSkipVerify bool   //不校验服务端证书，只用于开发环境
+}
+
+// isEmpty 没有任何配置时不使用TLS
+func (o *TLSOptions) isEmpty() bool {
+	if o == nil {
+		return true
+	}
+	return o.CertPath == "" && o.KeyPath == "" && o.CAPath == "" &&
+		o.ServerName == "" && o.MinVersion == 0 && !o.InsecureSkipVerify
+}
+
+// fingerprint TLS配置的摘要，用于连接的缓存key，配置变化时使用新的连接
+func (o *TLSOptions) fingerprint() string {
+	if o.isEmpty() {
+		return ""
+	}
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s|%s|%s|%s|%d|%t", o.CertPath, o.KeyPath, o.CAPath,
+		o.ServerName, o.MinVersion, o.InsecureSkipVerify)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// getTLSConfig 生成 tls.Config，没有配置时返回nil
+func (o *TLSOptions) getTLSConfig() (*tls.Config, error) {
+	if o.isEmpty() {
+		return nil, nil
+	}
+	if (o.CertPath == "") != (o.KeyPath == "") {
+		return nil, fmt.Errorf("tls certPath and keyPath must be set together")
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:         o.ServerName,
+		MinVersion:         o.MinVersion,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+	if tlsConfig.MinVersion == 0 {
+		tlsConfig.MinVersion = tls.VersionTLS12
+	}
+
+	if o.CAPath != "" {
+		caData, err := os.ReadFile(o.CAPath)
+		if err != nil {
+			return nil, err
+		}
+		certPool := x509.NewCertPool()
+		if !certPool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("tls ca %s has no valid certificate", o.CAPath)
+		}
+		tlsConfig.RootCAs = certPool
+	}
+
+	if o.CertPath != "" {
+		reloader, err := newCertReloader(o.CertPath, o.KeyPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.GetClientCertificate = reloader.GetClientCertificate
+	}
+	return tlsConfig, nil
+}
+
+// certReloader 握手时检查证书文件是否更新，更新后重新加载，证书轮换时不需要重启
+type certReloader struct {
+	certPath string
+	keyPath  string
+
+	lock     sync.Mutex
+	cert     *tls.Certificate
+	certTime time.Time
+	keyTime  time.Time
+}
+
+func newCertReloader(certPath, keyPath string) (*certReloader, error) {
+	r := &certReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+	}
+	if err := r.reload(); err != nil {
+		logs.DefaultLogger().Error("Unable to load cert and key pair.", err)
+		return nil, err
+	}
+	return r, nil
+}
+
+// reload 文件修改时间有变化时重新加载
+func (r *certReloader) reload() error {
+	certInfo, err := os.Stat(r.certPath)
+	if err != nil {
+		return err
+	}
+	keyInfo, err := os.Stat(r.keyPath)
+	if err != nil {
+		return err
+	}
+	if r.cert != nil && certInfo.ModTime().Equal(r.certTime) && keyInfo.ModTime().Equal(r.keyTime) {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	r.certTime = certInfo.ModTime()
+	r.keyTime = keyInfo.ModTime()
+	return nil
+}
+
+// GetClientCertificate 加载失败时继续使用之前的证书，比如证书和key只更新了一个的时候
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if err := r.reload(); err != nil {
+		logs.DefaultLogger().Error("reload cert and key pair error:", r.certPath, err)
+	}
+	return r.cert, nil
+}
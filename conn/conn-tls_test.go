@@ -0,0 +1,141 @@
+package conn
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCert 生成自签名的证书和key写入文件，返回证书的DER
+func writeTestCert(t *testing.T, certPath, keyPath string, serial int64, modTime time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: "temporal-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if keyPath != "" {
+		if err = os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err = os.Chtimes(keyPath, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.Chtimes(certPath, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestCertReloaderRotation(t *testing.T) {
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "client.pem")
+	keyPath := filepath.Join(dir, "client.key")
+	t0 := time.Now().Add(-time.Minute)
+	writeTestCert(t, certPath, keyPath, 1, t0)
+
+	tlsConfig, err := (&TLSOptions{CertPath: certPath, KeyPath: keyPath}).getTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tlsConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil || leaf.SerialNumber.Int64() != 1 {
+		t.Fatalf("unexpected first cert: %v", err)
+	}
+
+	//证书轮换后，下次握手使用新的证书
+	writeTestCert(t, certPath, keyPath, 2, t0.Add(time.Second))
+	cert, err = tlsConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil || leaf.SerialNumber.Int64() != 2 {
+		t.Fatalf("rotated cert not loaded: %v", err)
+	}
+
+	//只更新了证书，和key不匹配时继续使用之前的证书
+	writeTestCert(t, certPath, "", 3, t0.Add(2*time.Second))
+	cert, err = tlsConfig.GetClientCertificate(&tls.CertificateRequestInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil || leaf.SerialNumber.Int64() != 2 {
+		t.Fatalf("expected previous cert kept: %v", err)
+	}
+}
+
+func TestTLSConfigDefaults(t *testing.T) {
+	dir := t.TempDir()
+	caPath := filepath.Join(dir, "ca.pem")
+	caDer := writeTestCert(t, caPath, "", 1, time.Now())
+	caCert, err := x509.ParseCertificate(caDer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tlsConfig, err := (&TLSOptions{CAPath: caPath, ServerName: "temporal.example.com"}).getTLSConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedPool := x509.NewCertPool()
+	expectedPool.AddCert(caCert)
+	if tlsConfig.RootCAs == nil || !tlsConfig.RootCAs.Equal(expectedPool) {
+		t.Fatal("ca bundle not applied")
+	}
+	if tlsConfig.MinVersion != tls.VersionTLS12 || tlsConfig.ServerName != "temporal.example.com" ||
+		tlsConfig.GetClientCertificate != nil {
+		t.Fatalf("unexpected tls config: %+v", tlsConfig)
+	}
+
+	if tlsConfig, err = (&TLSOptions{MinVersion: tls.VersionTLS13}).getTLSConfig(); err != nil ||
+		tlsConfig.MinVersion != tls.VersionTLS13 || tlsConfig.RootCAs != nil {
+		t.Fatalf("unexpected tls config: %+v %v", tlsConfig, err)
+	}
+	if tlsConfig, err = (*TLSOptions)(nil).getTLSConfig(); err != nil || tlsConfig != nil {
+		t.Fatalf("expected no tls config: %v", err)
+	}
+
+	badCaPath := filepath.Join(dir, "bad-ca.pem")
+	if err = os.WriteFile(badCaPath, []byte("not a cert"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = (&TLSOptions{CAPath: badCaPath}).getTLSConfig(); err == nil {
+		t.Fatal("expected error for invalid ca bundle")
+	}
+	if _, err = (&TLSOptions{CertPath: caPath}).getTLSConfig(); err == nil {
+		t.Fatal("expected error for cert without key")
+	}
+}
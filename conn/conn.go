@@ -1,53 +1,133 @@
 package conn
 
 import (
-	"crypto/tls"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	dataConn "github.com/tianlin0/plat-lib/conn"
 	"github.com/tianlin0/plat-lib/logs"
+	"github.com/tianlin0/temporal/codec"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
 	"net"
-	"sync"
+	"strings"
 )
 
-var (
-	clientMapStore = sync.Map{}
-)
+// ClientOptions 连接temporal的配置
+type ClientOptions struct {
+	Namespace          string            //为空时兼容之前的用法，使用 Connect.Username
+	EnsureNamespace    *NamespaceOptions //设置后连接时检查namespace，不存在时自动创建
+	TLS                *TLSOptions
+	Credentials        *CredentialOptions
+	PayloadCodecs      []converter.PayloadCodec        //payload的编码，比如加密，worker和提交流程的一方需要使用相同的配置
+	MetricsHandler     client.MetricsHandler           //client和worker的指标，如 metrics.NewHandler()
+	Interceptors       []interceptor.ClientInterceptor //同时实现了 WorkerInterceptor 的，worker也会使用
+	ContextPropagators []workflow.ContextPropagator    //提交流程时ctx中的数据传递到流程和activity，worker也会使用
+}
+
+// GetDataConverter 连接使用的 DataConverter，查询历史时用它解码payload
+func (opts *ClientOptions) GetDataConverter() converter.DataConverter {
+	return codec.NewDataConverter(opts.PayloadCodecs...)
+}
+
+// getInstanceFingerprint payload编码、指标、拦截器、ctx传递等实例的摘要
+func (opts *ClientOptions) getInstanceFingerprint() string {
+	if len(opts.PayloadCodecs) == 0 && opts.MetricsHandler == nil && len(opts.Interceptors) == 0 &&
+		len(opts.ContextPropagators) == 0 {
+		return ""
+	}
+	idList := make([]string, 0, len(opts.PayloadCodecs)+len(opts.Interceptors)+len(opts.ContextPropagators)+1)
+	for _, one := range opts.PayloadCodecs {
+		idList = append(idList, getInstanceId(one))
+	}
+	idList = append(idList, getInstanceId(opts.MetricsHandler))
+	for _, one := range opts.Interceptors {
+		idList = append(idList, getInstanceId(one))
+	}
+	for _, one := range opts.ContextPropagators {
+		idList = append(idList, getInstanceId(one))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(idList, "|")))
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// getNamespace 连接使用的namespace
+func (opts *ClientOptions) getNamespace(conn *dataConn.Connect) string {
+	if opts.Namespace != "" {
+		return opts.Namespace
+	}
+	if conn.Username != "" {
+		return conn.Username
+	}
+	return client.DefaultNamespace
+}
+
+// getClientCacheKey 连接的缓存key，包含 Connect 的全部配置，密码等只记录摘要
+func getClientCacheKey(conn *dataConn.Connect, opts *ClientOptions) string {
+	return fmt.Sprintf("%s@%s:%s/%s/%s/%s/%s", opts.getNamespace(conn), conn.Host, conn.Port, getConnectFingerprint(conn),
+		opts.TLS.fingerprint(), opts.Credentials.fingerprint(), opts.getInstanceFingerprint())
+}
 
-func getClientCacheKey(conn *dataConn.Connect, certPath, keyPath string) string {
-	return fmt.Sprintf("%s@%s:%s/%s/%s", conn.Username, conn.Host, conn.Port, certPath, keyPath)
+// getConnectFingerprint Connect 配置的摘要
+func getConnectFingerprint(conn *dataConn.Connect) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%+v", *conn)))
+	return hex.EncodeToString(sum[:])[:16]
 }
 
+// GetTemporalClient 获取temporal连接，只使用客户端证书
 func GetTemporalClient(conn *dataConn.Connect, certPath, keyPath string) (client.Client, error) {
-	cacheKey := getClientCacheKey(conn, certPath, keyPath)
-	if c, ok := clientMapStore.Load(cacheKey); ok {
-		if clientTemp, ok := c.(client.Client); ok {
-			return clientTemp, nil
-		}
-	}
-
-	var cert tls.Certificate
-	var hasCert = false
-	if keyPath != "" && certPath != "" {
-		var err error
-		cert, err = tls.LoadX509KeyPair(certPath, keyPath)
-		hasCert = true
-		if err != nil {
-			hasCert = false
-			logs.DefaultLogger().Error("Unable to load cert and key pair.", err)
-			return nil, err
-		}
+	return GetTemporalClientWithOptions(conn, &ClientOptions{
+		TLS: &TLSOptions{
+			CertPath: certPath,
+			KeyPath:  keyPath,
+		},
+	})
+}
+
+// GetTemporalClientWithOptions 根据配置获取temporal连接，相同的配置复用同一个连接
+// 连接定期检查健康状态，不可用时从缓存中剔除，下次获取时重新建立
+func GetTemporalClientWithOptions(conn *dataConn.Connect, opts *ClientOptions) (client.Client, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("connect is nil")
+	}
+	if opts == nil {
+		opts = new(ClientOptions)
+	}
+	var metrics client.MetricsHandler
+	if opts.MetricsHandler != nil {
+		metrics = opts.MetricsHandler.WithTags(map[string]string{MetricTagNamespace: opts.getNamespace(conn)})
+	}
+	return defaultPool.get(getClientCacheKey(conn, opts), metrics, func() (client.Client, error) {
+		return dialTemporalClient(conn, opts)
+	})
+}
+
+// dialTemporalClient 新建temporal连接
+func dialTemporalClient(conn *dataConn.Connect, opts *ClientOptions) (client.Client, error) {
+	tlsConfig, err := opts.TLS.getTLSConfig()
+	if err != nil {
+		logs.DefaultLogger().Error("Unable to load tls config.", err)
+		return nil, err
 	}
 
 	hostPort := net.JoinHostPort(conn.Host, conn.Port)
+	namespace := opts.getNamespace(conn)
 	dialOption := client.Options{
-		HostPort:          hostPort,
-		Namespace:         conn.Username,
-		ConnectionOptions: client.ConnectionOptions{},
+		HostPort:           hostPort,
+		Namespace:          namespace,
+		DataConverter:      opts.GetDataConverter(),
+		MetricsHandler:     opts.MetricsHandler,
+		Interceptors:       opts.Interceptors,
+		ContextPropagators: opts.ContextPropagators,
+		ConnectionOptions: client.ConnectionOptions{
+			TLS: tlsConfig,
+		},
 	}
-
-	if hasCert {
-		dialOption.ConnectionOptions.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	if err = opts.Credentials.setClientOptions(&dialOption); err != nil {
+		logs.DefaultLogger().Error("Unable to load credentials.", err)
+		return nil, err
 	}
 
 	temporalClient, err := client.Dial(dialOption)
@@ -55,8 +135,10 @@ func GetTemporalClient(conn *dataConn.Connect, certPath, keyPath string) (client
 		logs.DefaultLogger().Error("Unable to connect to Temporal Cloud.", err)
 		return nil, err
 	}
-
-	clientMapStore.Store(cacheKey, temporalClient)
-
+	if err = opts.EnsureNamespace.ensureNamespace(temporalClient, namespace); err != nil {
+		logs.DefaultLogger().Error("Unable to ensure namespace.", namespace, err)
+		temporalClient.Close()
+		return nil, err
+	}
 	return temporalClient, nil
 }
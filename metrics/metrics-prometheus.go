@@ -0,0 +1,88 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	contentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+// ServeHTTP 以 Prometheus 文本格式输出所有指标
+func (h *Handler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	bw := bufio.NewWriter(w)
+	h.writeText(bw)
+	_ = bw.Flush()
+}
+
+// ListenAndServe 在本地地址上提供 /metrics 接口，如 go handler.ListenAndServe(":9090")
+func (h *Handler) ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", h)
+	return http.ListenAndServe(addr, mux)
+}
+
+// writeText 按指标名排序输出，同名的指标一起输出
+func (h *Handler) writeText(w *bufio.Writer) {
+	r := h.reg
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	keyList := make([]string, 0, len(r.series))
+	for key := range r.series {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+
+	lastName := ""
+	for _, key := range keyList {
+		s := r.series[key]
+		if s.name != lastName {
+			_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", s.name, s.kind)
+			lastName = s.name
+		}
+		if s.kind != kindHistogram {
+			_, _ = fmt.Fprintf(w, "%s%s %s\n", s.name, getLabelText(s.labels, ""), formatFloat(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, bound := range r.buckets {
+			cumulative += s.buckets[i]
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", s.name, getLabelText(s.labels, formatFloat(bound)), cumulative)
+		}
+		_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", s.name, getLabelText(s.labels, "+Inf"), s.count)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", s.name, getLabelText(s.labels, ""), formatFloat(s.sum))
+		_, _ = fmt.Fprintf(w, "%s_count%s %d\n", s.name, getLabelText(s.labels, ""), s.count)
+	}
+}
+
+// getLabelText 生成 {a="1",b="2"}，le 不为空时增加 histogram 的 le 标签
+func getLabelText(labels [][2]string, le string) string {
+	if len(labels) == 0 && le == "" {
+		return ""
+	}
+	partList := make([]string, 0, len(labels)+1)
+	for _, one := range labels {
+		partList = append(partList, one[0]+"=\""+escapeLabelValue(one[1])+"\"")
+	}
+	if le != "" {
+		partList = append(partList, "le=\""+le+"\"")
+	}
+	return "{" + strings.Join(partList, ",") + "}"
+}
+
+func escapeLabelValue(val string) string {
+	val = strings.ReplaceAll(val, `\`, `\\`)
+	val = strings.ReplaceAll(val, `"`, `\"`)
+	return strings.ReplaceAll(val, "\n", `\n`)
+}
+
+func formatFloat(val float64) string {
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"go.temporal.io/sdk/client"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	kindCounter   = "counter"
+	kindGauge     = "gauge"
+	kindHistogram = "histogram"
+)
+
+var (
+	// defaultBuckets 耗时的分桶，单位秒，覆盖从毫秒级的请求到小时级的审批等待
+	defaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 3600, 14400}
+
+	invalidNameRegexp = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+)
+
+// series 一个指标名和一组标签对应的值
+type series struct {
+	name   string
+	kind   string
+	labels [][2]string
+
+	value   float64  //counter、gauge 的值
+	buckets []uint64 //histogram 每个桶的数量，不累加
+	sum     float64
+	count   uint64
+}
+
+// registry 保存所有指标
+type registry struct {
+	lock    sync.Mutex
+	series  map[string]*series
+	buckets []float64
+}
+
+func (r *registry) getSeries(name, kind string, labels [][2]string) *series {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, one := range labels {
+		b.WriteString("\x00")
+		b.WriteString(one[0])
+		b.WriteString("=")
+		b.WriteString(one[1])
+	}
+	key := b.String()
+	s, ok := r.series[key]
+	if !ok {
+		s = &series{
+			name:   name,
+			kind:   kind,
+			labels: labels,
+		}
+		if kind == kindHistogram {
+			s.buckets = make([]uint64, len(r.buckets))
+		}
+		r.series[key] = s
+	}
+	return s
+}
+
+// Handler 实现 client.MetricsHandler，在内存中汇总指标，通过 ServeHTTP 以 Prometheus 文本格式输出
+// 设置到 starter.Config.MetricsHandler 后，temporal client、worker 以及 DSL 流程的指标都会记录在这里
+type Handler struct {
+	reg  *registry
+	tags map[string]string
+}
+
+// NewHandler 新建指标的 Handler
+func NewHandler() *Handler {
+	return &Handler{
+		reg: &registry{
+			series:  make(map[string]*series),
+			buckets: defaultBuckets,
+		},
+		tags: make(map[string]string),
+	}
+}
+
+// WithTags 返回增加了标签的 Handler，与原来的 Handler 共用数据
+func (h *Handler) WithTags(tags map[string]string) client.MetricsHandler {
+	newTags := make(map[string]string, len(h.tags)+len(tags))
+	for key, val := range h.tags {
+		newTags[key] = val
+	}
+	for key, val := range tags {
+		newTags[key] = val
+	}
+	return &Handler{
+		reg:  h.reg,
+		tags: newTags,
+	}
+}
+
+// getLabels 标签按名字排序，名字中不合法的字符替换为下划线
+func (h *Handler) getLabels() [][2]string {
+	labels := make([][2]string, 0, len(h.tags))
+	for key, val := range h.tags {
+		labels = append(labels, [2]string{getMetricName(key), val})
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i][0] < labels[j][0]
+	})
+	return labels
+}
+
+func (h *Handler) Counter(name string) client.MetricsCounter {
+	return &metric{reg: h.reg, name: getMetricName(name), kind: kindCounter, labels: h.getLabels()}
+}
+
+func (h *Handler) Gauge(name string) client.MetricsGauge {
+	return &metric{reg: h.reg, name: getMetricName(name), kind: kindGauge, labels: h.getLabels()}
+}
+
+func (h *Handler) Timer(name string) client.MetricsTimer {
+	return &metric{reg: h.reg, name: getMetricName(name), kind: kindHistogram, labels: h.getLabels()}
+}
+
+// metric 实现 counter、gauge、timer
+type metric struct {
+	reg    *registry
+	name   string
+	kind   string
+	labels [][2]string
+}
+
+func (m *metric) Inc(delta int64) {
+	m.reg.lock.Lock()
+	defer m.reg.lock.Unlock()
+	m.reg.getSeries(m.name, m.kind, m.labels).value += float64(delta)
+}
+
+func (m *metric) Update(val float64) {
+	m.reg.lock.Lock()
+	defer m.reg.lock.Unlock()
+	m.reg.getSeries(m.name, m.kind, m.labels).value = val
+}
+
+// Record 记录耗时，单位秒
+func (m *metric) Record(d time.Duration) {
+	seconds := d.Seconds()
+	m.reg.lock.Lock()
+	defer m.reg.lock.Unlock()
+	s := m.reg.getSeries(m.name, m.kind, m.labels)
+	for i, bound := range m.reg.buckets {
+		if seconds <= bound {
+			s.buckets[i]++
+			break
+		}
+	}
+	s.sum += seconds
+	s.count++
+}
+
+// getMetricName Prometheus 的指标名和标签名只能包含字母、数字和下划线
+func getMetricName(name string) string {
+	return invalidNameRegexp.ReplaceAllString(name, "_")
+}
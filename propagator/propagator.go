@@ -0,0 +1,199 @@
+package propagator
+
+import (
+	"context"
+	"github.com/tianlin0/plat-lib/logs"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	HeaderKey   = "_ctx-data" //temporal header 中保存ctx数据的key
+	KeyLogId    = "logId"     //plat-lib 日志的 logId
+	KeyOperator = "operator"  //操作人
+	KeyTenant   = "tenant"    //租户
+)
+
+// ctxKey 本包在ctx中保存数据使用的key，避免与业务的key冲突
+type ctxKey string
+
+// valueGetter context.Context 和 workflow.Context 都可以取值
+type valueGetter interface {
+	Value(key interface{}) interface{}
+}
+
+// Options 需要传递的ctx数据，logId、操作人、租户默认传递
+type Options struct {
+	Keys []string //额外传递的ctx中的key，如 context.WithValue(ctx, "aaa", ...)，值需要能json序列化，还原后为json的类型
+}
+
+// contextPropagator 实现 workflow.ContextPropagator
+type contextPropagator struct {
+	keys []string
+}
+
+// NewContextPropagator 新建ctx数据的传递，设置到 starter.Config.ContextPropagators 后，
+// 提交流程时ctx中的数据会写入header，在流程、activity、子流程（如 onExit: return 启动的后续流程）的ctx中还原
+func NewContextPropagator(opts *Options) workflow.ContextPropagator {
+	p := new(contextPropagator)
+	if opts != nil {
+		p.keys = append(p.keys, opts.Keys...)
+	}
+	return p
+}
+
+// WithOperator 在ctx中设置操作人
+func WithOperator(ctx context.Context, operator string) context.Context {
+	return context.WithValue(ctx, ctxKey(KeyOperator), operator)
+}
+
+// WithTenant 在ctx中设置租户
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, ctxKey(KeyTenant), tenant)
+}
+
+// GetOperator 获取操作人，ctx 可以是 context.Context 或者 workflow.Context
+func GetOperator(ctx valueGetter) string {
+	return getString(ctx, KeyOperator)
+}
+
+// GetTenant 获取租户，ctx 可以是 context.Context 或者 workflow.Context
+func GetTenant(ctx valueGetter) string {
+	return getString(ctx, KeyTenant)
+}
+
+// GetLogId 获取 logId，没有传递过来时使用 plat-lib 日志中的 logId
+func GetLogId(ctx valueGetter) string {
+	if logId := getString(ctx, KeyLogId); logId != "" {
+		return logId
+	}
+	if newCtx, ok := ctx.(context.Context); ok {
+		return logs.CtxLogger(newCtx).LogId(newCtx)
+	}
+	return ""
+}
+
+func getString(ctx valueGetter, key string) string {
+	if ctx == nil {
+		return ""
+	}
+	val, _ := ctx.Value(ctxKey(key)).(string)
+	return val
+}
+
+// getValues 获取需要传递的数据
+func (p *contextPropagator) getValues(ctx valueGetter) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, key := range []string{KeyLogId, KeyOperator, KeyTenant} {
+		if val := getString(ctx, key); val != "" {
+			values[key] = val
+		}
+	}
+	for _, key := range p.keys {
+		if val := ctx.Value(key); val != nil {
+			values[key] = val
+		}
+	}
+	return values
+}
+
+// write 写入header，没有数据时不写
+func write(values map[string]interface{}, writer workflow.HeaderWriter) error {
+	if len(values) == 0 {
+		return nil
+	}
+	payload, err := converter.GetDefaultDataConverter().ToPayload(values)
+	if err != nil {
+		return err
+	}
+	writer.Set(HeaderKey, payload)
+	return nil
+}
+
+// read 从header读取数据
+func read(reader workflow.HeaderReader) (map[string]interface{}, error) {
+	payload, ok := reader.Get(HeaderKey)
+	if !ok {
+		return nil, nil
+	}
+	values := make(map[string]interface{})
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Inject 提交流程时从ctx中取数据写入header
+func (p *contextPropagator) Inject(ctx context.Context, writer workflow.HeaderWriter) error {
+	values := p.getValues(ctx)
+	if _, ok := values[KeyLogId]; !ok {
+		if logId := GetLogId(ctx); logId != "" {
+			values[KeyLogId] = logId
+		}
+	}
+	return write(values, writer)
+}
+
+// Extract activity中从header还原数据到ctx，只还原内置的key和 Options.Keys 中的key，logId 设置到 plat-lib 的日志中，logs.CtxLogger(ctx) 打印时带上
+func (p *contextPropagator) Extract(ctx context.Context, reader workflow.HeaderReader) (context.Context, error) {
+	values, err := read(reader)
+	if err != nil || len(values) == 0 {
+		return ctx, err
+	}
+	for key, val := range values {
+		if p.isBuiltinKey(key) {
+			ctx = context.WithValue(ctx, ctxKey(key), val)
+			continue
+		}
+		if p.isConfiguredKey(key) {
+			ctx = context.WithValue(ctx, key, val)
+		}
+	}
+	if logId := GetLogId(ctx); logId != "" {
+		//屏蔽上层ctx中的日志实例，避免修改worker共用的日志
+		ctx = context.WithValue(ctx, logs.GetConfig().LoggerCtxName, nil)
+		_, ctx = logs.NewCtxLogger(ctx, logs.INFO, nil, &logs.LogCommData{
+			LogId:  logId,
+			UserId: GetOperator(ctx),
+		})
+	}
+	return ctx, nil
+}
+
+// InjectFromWorkflow 流程中执行activity、子流程时写入header
+func (p *contextPropagator) InjectFromWorkflow(ctx workflow.Context, writer workflow.HeaderWriter) error {
+	return write(p.getValues(ctx), writer)
+}
+
+// ExtractToWorkflow 流程开始时从header还原数据到流程的ctx，只还原内置的key和 Options.Keys 中的key
+func (p *contextPropagator) ExtractToWorkflow(ctx workflow.Context, reader workflow.HeaderReader) (workflow.Context, error) {
+	values, err := read(reader)
+	if err != nil || len(values) == 0 {
+		return ctx, err
+	}
+	for key, val := range values {
+		if p.isBuiltinKey(key) {
+			ctx = workflow.WithValue(ctx, ctxKey(key), val)
+			continue
+		}
+		if p.isConfiguredKey(key) {
+			ctx = workflow.WithValue(ctx, key, val)
+		}
+	}
+	return ctx, nil
+}
+
+// isBuiltinKey logId、操作人、租户使用本包的key保存
+func (p *contextPropagator) isBuiltinKey(key string) bool {
+	return key == KeyLogId || key == KeyOperator || key == KeyTenant
+}
+
+// isConfiguredKey 是否为 Options.Keys 中配置的key，header中其他的key不还原，避免调用方向worker的ctx中注入任意数据
+func (p *contextPropagator) isConfiguredKey(key string) bool {
+	for _, one := range p.keys {
+		if one == key {
+			return true
+		}
+	}
+	return false
+}
@@ -2,30 +2,45 @@ package starter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	dataConn "github.com/tianlin0/plat-lib/conn"
 	"github.com/tianlin0/temporal/activity"
 	"github.com/tianlin0/temporal/conn"
 	"github.com/tianlin0/temporal/worker"
+	dsl "github.com/tianlin0/temporal/workflow"
 	"go.temporal.io/api/common/v1"
-	"go.temporal.io/api/enums/v1"
-	v11 "go.temporal.io/api/enums/v1"
-	historypb "go.temporal.io/api/history/v1"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/workflow"
 )
 
 // Config 配置文件
 type Config struct {
-	Connect        *dataConn.Connect //必填
-	CertPath       string
-	KeyPath        string
-	TaskQueueName  string                      //队列名
-	WorkerFlow     interface{}                 //流程
-	ActivityList   []activity.TemplateActivity //必填
-	ActivityOption *workflow.ActivityOptions
+	Connect            *dataConn.Connect      //必填
+	Namespace          string                 //temporal的namespace，为空时使用 Connect.Username
+	EnsureNamespace    *conn.NamespaceOptions //设置后启动时检查namespace，不存在时自动创建，用于本地开发和测试环境
+	CertPath           string
+	KeyPath            string
+	TLS                *conn.TLSOptions                //TLS配置，设置后 CertPath、KeyPath 为空时使用其中的证书
+	Credentials        *conn.CredentialOptions         //认证配置，API key、token文件或者自定义header
+	PayloadCodecs      []converter.PayloadCodec        //payload的编码，比如 codec.NewAESCodec 加密，worker和提交流程的一方需要相同
+	MetricsHandler     client.MetricsHandler           //指标，如 metrics.NewHandler()，设置后同时记录DSL流程的指标
+	Interceptors       []interceptor.ClientInterceptor //拦截器，如 tracing.NewInterceptor(nil)，需要创建一次后复用
+	ContextPropagators []workflow.ContextPropagator    //ctx数据的传递，如 propagator.NewContextPropagator(nil)，需要创建一次后复用
+	TaskQueueName      string                          //队列名
+	WorkerFlow         interface{}                     //流程
+	ActivityList       []activity.TemplateActivity     //必填
+	ActivityOption     *workflow.ActivityOptions
+	DslLoader          func(name string) (*dsl.DslWorkflow, error) //根据名字获取流程定义，定时任务使用
 }
 
+var (
+	// dslMetricsInterceptor 将DSL流程的名字传到activity，记录重试次数和日志使用，所有连接共用一个，保证连接的缓存key不变
+	dslMetricsInterceptor = dsl.NewMetricsInterceptor()
+)
+
 type startUp struct {
 	registered bool
 	cfg        *Config
@@ -38,6 +53,50 @@ func New(cfg *Config) *startUp {
 	return su
 }
 
+// getTemporalClient 获取配置对应的temporal连接
+func (su *startUp) getTemporalClient() (client.Client, error) {
+	return conn.GetTemporalClientWithOptions(su.cfg.Connect, su.cfg.getClientOptions())
+}
+
+// getClientOptions 连接配置，兼容只设置了 CertPath、KeyPath 的情况
+func (cfg *Config) getClientOptions() *conn.ClientOptions {
+	tlsOptions := new(conn.TLSOptions)
+	if cfg.TLS != nil {
+		*tlsOptions = *cfg.TLS
+	}
+	if cfg.CertPath != "" && cfg.KeyPath != "" {
+		tlsOptions.CertPath = cfg.CertPath
+		tlsOptions.KeyPath = cfg.KeyPath
+	}
+	opts := &conn.ClientOptions{
+		Namespace:          cfg.Namespace,
+		EnsureNamespace:    cfg.EnsureNamespace,
+		TLS:                tlsOptions,
+		Credentials:        cfg.Credentials,
+		PayloadCodecs:      cfg.PayloadCodecs,
+		MetricsHandler:     cfg.MetricsHandler,
+		Interceptors:       append([]interceptor.ClientInterceptor{}, cfg.Interceptors...),
+		ContextPropagators: cfg.ContextPropagators,
+	}
+	opts.Interceptors = append(opts.Interceptors, dslMetricsInterceptor)
+	return opts
+}
+
+// getDataConverter 配置对应的 DataConverter，解码历史中的payload
+func (su *startUp) getDataConverter() converter.DataConverter {
+	return su.cfg.getClientOptions().GetDataConverter()
+}
+
+// DecodePayloads 使用配置的codec解码payload，如历史事件中activity的输入和输出，解码失败的保留原始内容
+func (su *startUp) DecodePayloads(payloads *common.Payloads) []interface{} {
+	return decodePayloads(su.getDataConverter(), payloads)
+}
+
+// DecodePayload 使用配置的codec解码一个payload到 valuePtr
+func (su *startUp) DecodePayload(payload *common.Payload, valuePtr interface{}) error {
+	return su.getDataConverter().FromPayload(payload, valuePtr)
+}
+
 // Start 启动注册服务
 func (su *startUp) Start(useRun bool) error {
 	cfg := su.cfg
@@ -50,7 +109,7 @@ func (su *startUp) Start(useRun bool) error {
 		return fmt.Errorf("cfg %s param error", cfg.TaskQueueName)
 	}
 
-	temporalClient, err := conn.GetTemporalClient(cfg.Connect, cfg.CertPath, cfg.KeyPath)
+	temporalClient, err := su.getTemporalClient()
 	if err != nil {
 		return err
 	}
@@ -68,13 +127,19 @@ func (su *startUp) Start(useRun bool) error {
 // Submit 提交一条流程
 // args 为执行 cfg.WorkerFlow 除了ctx后面的参数列表
 func (su *startUp) Submit(ctx context.Context, workflowId string, args ...interface{}) (client.WorkflowRun, error) {
+	return su.SubmitWithOptions(ctx, &worker.SubmitOptions{WorkflowId: workflowId}, args...)
+}
+
+// SubmitWithOptions 根据配置提交一条流程，可以指定workflowId的生成方式、重复id的处理方式、超时时间等
+// 相同id的流程已存在并且 WorkflowIDConflictPolicy 为 FAIL（IdPolicyExact、IdPolicyTemplate 的默认值）时，返回 *worker.WorkflowAlreadyRunningError
+func (su *startUp) SubmitWithOptions(ctx context.Context, opts *worker.SubmitOptions, args ...interface{}) (client.WorkflowRun, error) {
 	cfg := su.cfg
 	if cfg.TaskQueueName == "" ||
 		cfg.WorkerFlow == nil {
 		return nil, fmt.Errorf("cfg %s param error", cfg.TaskQueueName)
 	}
 
-	temporalClient, err := conn.GetTemporalClient(cfg.Connect, cfg.CertPath, cfg.KeyPath)
+	temporalClient, err := su.getTemporalClient()
 	if err != nil {
 		return nil, err
 	}
@@ -82,221 +147,58 @@ func (su *startUp) Submit(ctx context.Context, workflowId string, args ...interf
 	if err != nil {
 		return nil, err
 	}
-	wr, err := cw.ExecuteWorkflow(ctx, workflowId, cfg.WorkerFlow, args...)
+	wr, err := cw.ExecuteWorkflowWithOptions(ctx, withDslNameMemo(opts, args), cfg.WorkerFlow, args...)
 	if err != nil {
+		var runningErr *worker.WorkflowAlreadyRunningError
+		if errors.As(err, &runningErr) {
+			return wr, err
+		}
 		return wr, activity.New().GetErrorByTemporalError(err)
 	}
 	return wr, nil
 }
 
-// GetAllLogList 获取指定任务队列中工作流的全部日志或者某个运行中的步骤的日志列表。
-// 如果 isAll 为 false，则只返回当前运行中的步骤的日志；否则返回所有历史步骤的日志。
-// 参数：
-//   - taskQueue: 任务队列名称
-//   - workflowId: 工作流 ID
-//   - runId: 当前运行的 ID (empty for all runs)
-//   - isAll: 是否获取所有历史步骤的日志 (true 时返回完整的日志列表，false 时仅返回当前运行中的步骤的日志)
-//
-// 返回：
-//   - 如果 isAll 为 true，则返回包含 "steps" 和 "logs" 字段的映射，其中 "steps" 是任务队列中所有步骤的信息，"logs" 是所有历史步骤的日志;
-//     如果 isAll 为 false，则返回 *([]*OneLog)，即仅包含当前运行中的步骤的日志的切片。
-type actHistoryEvent struct {
-	StartEvent       *actOneHistoryEvent
-	CompleteEvent    *actOneHistoryEvent
-	ActivityTypeName string
-	Payloads         []*common.Payload
-	FailureMessage   string
-	TaskFailed       bool
-	UseTime          int64 //使用了多长时间，毫秒
-}
-
-type actOneHistoryEvent struct {
-	HistoryEvent *historypb.HistoryEvent
-	TaskQueue    string
-	WorkflowId   string
-	RunId        string
-}
-
-func getAllHistoryEvent(temporalClient client.Client, taskQueue string, workflowId, runId string) []*actOneHistoryEvent {
-	iter := temporalClient.GetWorkflowHistory(context.Background(), workflowId, runId, false, enums.HISTORY_EVENT_FILTER_TYPE_UNSPECIFIED)
-	historyList := make([]*historypb.HistoryEvent, 0)
-	for iter.HasNext() {
-		event, err := iter.Next()
-		if err != nil {
-			break
-		}
-		historyList = append(historyList, event)
-	}
-
-	actList := make([]*actOneHistoryEvent, 0)
-
-	if len(historyList) == 0 {
-		return actList
-	}
-
-	for _, one := range historyList {
-		actList = append(actList, &actOneHistoryEvent{
-			HistoryEvent: one,
-			TaskQueue:    taskQueue,
-			WorkflowId:   workflowId,
-			RunId:        runId,
-		})
-	}
-
-	//子流程
-	childWorkflowList := make([]*historypb.HistoryEvent, 0)
-	for _, one := range historyList {
-		child := one.GetChildWorkflowExecutionStartedEventAttributes()
-		if child != nil {
-			childWorkflowList = append(childWorkflowList, one)
-			continue
-		}
-	}
-
-	//子流程里取得列表
-	if len(childWorkflowList) > 0 {
-		for _, child := range childWorkflowList {
-			childAttr := child.GetChildWorkflowExecutionStartedEventAttributes()
-			childObject := childAttr.GetWorkflowExecution()
-			childList := getAllHistoryEvent(temporalClient, taskQueue, childObject.GetWorkflowId(), childObject.GetRunId())
-			actList = append(actList, childList...)
-		}
-	}
-	return actList
-}
-
-// GetAllLogList 获取历史记录
-func (su *startUp) GetAllLogList(taskQueue string, workflowId, runId string) ([]*actHistoryEvent, error) {
-	cfg := su.cfg
-	temporalClient, err := conn.GetTemporalClient(cfg.Connect, cfg.CertPath, cfg.KeyPath)
-	if err != nil {
-		return nil, err
+// withDslNameMemo 参数中有 DslWorkflow 时，将流程定义的名字写入memo，方便列表查询时展示
+func withDslNameMemo(opts *worker.SubmitOptions, args []interface{}) *worker.SubmitOptions {
+	if opts == nil {
+		opts = &worker.SubmitOptions{}
 	}
-
-	if taskQueue == "" {
-		return nil, fmt.Errorf("taskQueue is empty")
-	}
-
-	historyList := getAllHistoryEvent(temporalClient, taskQueue, workflowId, runId)
-
-	allActHistoryList := make([]*actHistoryEvent, 0)
-
-	if len(historyList) == 0 {
-		return allActHistoryList, nil
-	}
-
-	for _, one := range historyList {
-		attr := one.HistoryEvent.GetActivityTaskScheduledEventAttributes()
-		if attr == nil {
+	for _, arg := range args {
+		dslWorkflow, ok := arg.(*dsl.DslWorkflow)
+		if !ok || dslWorkflow == nil || dslWorkflow.Name == "" {
 			continue
 		}
-		activityName := attr.ActivityType.Name
-		if activityName != "" {
-			allActHistoryList = append(allActHistoryList, &actHistoryEvent{
-				StartEvent:       one,
-				ActivityTypeName: activityName,
-			})
+		if _, ok = opts.Memo[dsl.DslNameMemoKey]; ok {
+			return opts
 		}
-	}
-
-	//根据EventId 查询结果EventId
-	for _, oneLog := range allActHistoryList {
-		//首先查找是否完成的情况
-		isCompleted := false
-		for _, one := range historyList {
-			comp := one.HistoryEvent.GetActivityTaskCompletedEventAttributes()
-			if comp == nil {
-				continue
-			}
-			if comp.ScheduledEventId == oneLog.StartEvent.HistoryEvent.EventId &&
-				one.TaskQueue == oneLog.StartEvent.TaskQueue &&
-				one.WorkflowId == oneLog.StartEvent.WorkflowId &&
-				one.RunId == oneLog.StartEvent.RunId {
-
-				result := comp.GetResult()
-				dataList := result.GetPayloads()
-				oneLog.CompleteEvent = one
-				oneLog.Payloads = dataList
-				isCompleted = true
-				break
-			}
-		}
-
-		if isCompleted {
-			continue
-		}
-		//如果没有完成，则查询是否执行错误了
-		for _, one := range historyList {
-			comp := one.HistoryEvent.GetActivityTaskFailedEventAttributes()
-			if comp == nil {
-				continue
-			}
-			if comp.ScheduledEventId == oneLog.StartEvent.HistoryEvent.EventId &&
-				one.TaskQueue == oneLog.StartEvent.TaskQueue &&
-				one.WorkflowId == oneLog.StartEvent.WorkflowId &&
-				one.RunId == oneLog.StartEvent.RunId {
-
-				isCompleted = true
-				result := comp.Failure
-				oneLog.CompleteEvent = one
-				oneLog.FailureMessage = result.GetMessage()
-				oneLog.TaskFailed = true
-				break
-			}
-
-		}
-		if isCompleted {
-			continue
-		}
-	}
-	//需要计算耗时
-	for _, one := range allActHistoryList {
-		if one.CompleteEvent != nil {
-			one.UseTime = one.CompleteEvent.HistoryEvent.GetEventTime().AsTime().
-				Sub(one.StartEvent.HistoryEvent.GetEventTime().AsTime()).Milliseconds()
+		newOpts := *opts
+		newOpts.Memo = make(map[string]interface{}, len(opts.Memo)+1)
+		for k, v := range opts.Memo {
+			newOpts.Memo[k] = v
 		}
+		newOpts.Memo[dsl.DslNameMemoKey] = dslWorkflow.Name
+		return &newOpts
 	}
-	return allActHistoryList, nil
+	return opts
 }
 
-type workflowState struct {
-	Status     v11.WorkflowExecutionStatus
-	WorkflowId string
-	RunId      string
-}
-
-// GetAllWorkflowStatus 获取状态
-func (su *startUp) GetAllWorkflowStatus(workflowId, runId string) ([]*workflowState, error) {
-	cfg := su.cfg
-	temporalClient, err := conn.GetTemporalClient(cfg.Connect, cfg.CertPath, cfg.KeyPath)
+// GetByIdempotencyKey 根据提交时的幂等key获取流程
+func (su *startUp) GetByIdempotencyKey(ctx context.Context, idempotencyKey string) (client.WorkflowRun, error) {
+	if idempotencyKey == "" {
+		return nil, fmt.Errorf("idempotencyKey is empty")
+	}
+	temporalClient, err := su.getTemporalClient()
 	if err != nil {
 		return nil, err
 	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
 
-	descResp, err := temporalClient.DescribeWorkflowExecution(context.Background(), workflowId, runId)
+	workflowId := worker.GetIdempotencyWorkflowId(su.cfg.TaskQueueName, idempotencyKey)
+	descResp, err := temporalClient.DescribeWorkflowExecution(ctx, workflowId, "")
 	if err != nil {
 		return nil, err
 	}
-	allState := make([]*workflowState, 0)
-	allState = append(allState, &workflowState{
-		Status:     descResp.GetWorkflowExecutionInfo().GetStatus(),
-		WorkflowId: workflowId,
-		RunId:      runId,
-	})
-
-	childWorkflow := descResp.GetPendingChildren()
-	if childWorkflow != nil {
-		for _, one := range childWorkflow {
-			oneDescResp, errTemp := temporalClient.DescribeWorkflowExecution(context.Background(), one.GetWorkflowId(), one.GetRunId())
-			if errTemp != nil {
-				continue
-			}
-			allState = append(allState, &workflowState{
-				Status:     oneDescResp.GetWorkflowExecutionInfo().GetStatus(),
-				WorkflowId: one.GetWorkflowId(),
-				RunId:      one.GetRunId(),
-			})
-		}
-	}
-	return allState, nil
+	return temporalClient.GetWorkflow(ctx, workflowId, descResp.GetWorkflowExecutionInfo().GetExecution().GetRunId()), nil
 }
@@ -0,0 +1,77 @@
+package starter
+
+import (
+	"context"
+	"fmt"
+	"github.com/tianlin0/temporal/workflow"
+	"go.temporal.io/api/workflowservice/v1"
+)
+
+// PendingApprovalExecution 某个流程中等待审批的内容
+type PendingApprovalExecution struct {
+	WorkflowId string
+	RunId      string
+	Approvals  []*workflow.PendingApproval
+}
+
+// ListPendingApprovals 获取当前队列中所有等待审批的流程
+// 通过 search attribute DslPendingApprovals 进行可见性查询，再查询每个流程的审批详情
+func (su *startUp) ListPendingApprovals(ctx context.Context) ([]*PendingApprovalExecution, error) {
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	query := fmt.Sprintf("%s IS NOT NULL AND ExecutionStatus = 'Running'", workflow.PendingApprovalsSearchAttributeName)
+	if su.cfg.TaskQueueName != "" {
+		query = fmt.Sprintf("%s AND TaskQueue = '%s'", query, su.cfg.TaskQueueName)
+	}
+
+	allList := make([]*PendingApprovalExecution, 0)
+	var nextPageToken []byte
+	for {
+		resp, err := temporalClient.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         query,
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, one := range resp.GetExecutions() {
+			exe := one.GetExecution()
+			val, err := temporalClient.QueryWorkflow(ctx, exe.GetWorkflowId(), exe.GetRunId(), workflow.PendingApprovalsQueryName)
+			if err != nil {
+				return nil, err
+			}
+			approvals := make([]*workflow.PendingApproval, 0)
+			if err = val.Get(&approvals); err != nil {
+				return nil, err
+			}
+			if len(approvals) == 0 {
+				continue
+			}
+			allList = append(allList, &PendingApprovalExecution{
+				WorkflowId: exe.GetWorkflowId(),
+				RunId:      exe.GetRunId(),
+				Approvals:  approvals,
+			})
+		}
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			break
+		}
+	}
+	return allList, nil
+}
+
+// Approve 提交审批结果，审批不存在或者审批人不在指定列表中时返回错误
+func (su *startUp) Approve(ctx context.Context, workflowId, runId string, decision *workflow.ApprovalDecision) (*workflow.ApprovalDecision, error) {
+	ret := new(workflow.ApprovalDecision)
+	if err := su.updateWorkflow(ctx, workflowId, runId, workflow.ApprovalUpdateName, ret, decision); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
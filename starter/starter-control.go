@@ -0,0 +1,114 @@
+package starter
+
+import (
+	"context"
+	"github.com/tianlin0/temporal/workflow"
+	"go.temporal.io/sdk/client"
+)
+
+// updateWorkflow 发送update并等待处理完成，ret 为返回值的指针
+func (su *startUp) updateWorkflow(ctx context.Context, workflowId, runId string, updateName string,
+	ret interface{}, args ...interface{}) error {
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	handle, err := temporalClient.UpdateWorkflow(ctx, client.UpdateWorkflowOptions{
+		WorkflowID:   workflowId,
+		RunID:        runId,
+		UpdateName:   updateName,
+		Args:         args,
+		WaitForStage: client.WorkflowUpdateStageCompleted,
+	})
+	if err != nil {
+		return err
+	}
+	return handle.Get(ctx, ret)
+}
+
+// Pause 暂停流程，正在执行的activity会执行完，后续的activity等待 Resume
+func (su *startUp) Pause(ctx context.Context, workflowId, runId string, operator string) (*workflow.ControlState, error) {
+	state := new(workflow.ControlState)
+	err := su.updateWorkflow(ctx, workflowId, runId, workflow.PauseUpdateName, state, operator)
+	return state, err
+}
+
+// Resume 恢复暂停的流程
+func (su *startUp) Resume(ctx context.Context, workflowId, runId string, operator string) (*workflow.ControlState, error) {
+	state := new(workflow.ControlState)
+	err := su.updateWorkflow(ctx, workflowId, runId, workflow.ResumeUpdateName, state, operator)
+	return state, err
+}
+
+// SkipStep 跳过某个还未成功执行的activity，responses 作为该activity的返回值
+// 如果该activity正在等待失败处理，则直接跳过并继续执行
+func (su *startUp) SkipStep(ctx context.Context, workflowId, runId string, activityId string,
+	responses map[string]interface{}, operator string) (*workflow.ControlState, error) {
+	state := new(workflow.ControlState)
+	err := su.updateWorkflow(ctx, workflowId, runId, workflow.SkipStepUpdateName, state, &workflow.StepDecision{
+		ActivityId: activityId,
+		Responses:  responses,
+		Operator:   operator,
+	})
+	return state, err
+}
+
+// RetryStep 重试某个执行失败的activity，需要流程设置了 WaitOnFailure
+func (su *startUp) RetryStep(ctx context.Context, workflowId, runId string, activityId string,
+	operator string) (*workflow.ControlState, error) {
+	state := new(workflow.ControlState)
+	err := su.updateWorkflow(ctx, workflowId, runId, workflow.RetryStepUpdateName, state, &workflow.StepDecision{
+		ActivityId: activityId,
+		Operator:   operator,
+	})
+	return state, err
+}
+
+// GetControlState 查询流程的暂停状态，以及等待处理的失败activity
+func (su *startUp) GetControlState(ctx context.Context, workflowId, runId string) (*workflow.ControlState, error) {
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	val, err := temporalClient.QueryWorkflow(ctx, workflowId, runId, workflow.ControlStateQueryName)
+	if err != nil {
+		return nil, err
+	}
+	state := new(workflow.ControlState)
+	err = val.Get(state)
+	return state, err
+}
+
+// PatchVariables 修改运行中流程的变量，以及还未开始执行的activity的参数
+// 返回修改后还未执行的activity的实际参数，已经开始执行的activity不允许修改
+func (su *startUp) PatchVariables(ctx context.Context, workflowId, runId string,
+	patch *workflow.VariablesPatch) (*workflow.PatchResult, error) {
+	result := new(workflow.PatchResult)
+	err := su.updateWorkflow(ctx, workflowId, runId, workflow.PatchVariablesUpdateName, result, patch)
+	return result, err
+}
+
+// GetPatchHistory 查询运行中流程的所有修改记录，流程结束后可以通过memo中的 dslPatches 查看
+func (su *startUp) GetPatchHistory(ctx context.Context, workflowId, runId string) ([]*workflow.PatchRecord, error) {
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	val, err := temporalClient.QueryWorkflow(ctx, workflowId, runId, workflow.PatchHistoryQueryName)
+	if err != nil {
+		return nil, err
+	}
+	history := make([]*workflow.PatchRecord, 0)
+	err = val.Get(&history)
+	return history, err
+}
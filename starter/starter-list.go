@@ -0,0 +1,326 @@
+package starter
+
+import (
+	"context"
+	"fmt"
+	"github.com/tianlin0/plat-lib/conv"
+	"github.com/tianlin0/plat-lib/logs"
+	dsl "github.com/tianlin0/temporal/workflow"
+	"go.temporal.io/api/enums/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// failureMessageWorkers 获取失败原因时的最大并发数
+	failureMessageWorkers = 8
+)
+
+var (
+	// searchAttributeNameRegexp search attribute 的名字
+	searchAttributeNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+type (
+	// WorkflowExecution 可见性查询返回的一条流程
+	WorkflowExecution struct {
+		WorkflowId       string                        `json:"workflowId"`
+		RunId            string                        `json:"runId"`
+		WorkflowType     string                        `json:"workflowType"`
+		TaskQueue        string                        `json:"taskQueue"`
+		Status           enums.WorkflowExecutionStatus `json:"status"`
+		StartTime        time.Time                     `json:"startTime"`
+		CloseTime        time.Time                     `json:"closeTime,omitempty"` //运行中的流程为空
+		SearchAttributes map[string]interface{}        `json:"searchAttributes,omitempty"`
+		Memo             map[string]interface{}        `json:"memo,omitempty"`
+	}
+
+	// WorkflowFilter 流程列表的过滤条件，为空的条件不参与过滤
+	WorkflowFilter struct {
+		TaskQueue        string                          //为空时使用配置的队列名
+		WorkflowType     string                          //流程类型，如 DslWorkflow
+		Status           []enums.WorkflowExecutionStatus //满足其中一个状态即可
+		StartTimeFrom    time.Time
+		StartTimeTo      time.Time
+		CloseTimeFrom    time.Time
+		CloseTimeTo      time.Time
+		SearchAttributes map[string]interface{} //自定义的search attribute，需要相等
+		PageSize         int
+		NextPageToken    []byte //上一页返回的值，第一页为空
+	}
+
+	// WorkflowSummary 列表展示用的流程概要
+	WorkflowSummary struct {
+		*WorkflowExecution
+		DslName        string        `json:"dslName,omitempty"` //流程定义的名字
+		Duration       time.Duration `json:"duration"`          //执行时长，运行中的流程为到当前的时长
+		FailureMessage string        `json:"failureMessage,omitempty"`
+	}
+
+	// WorkflowSummaryPage 流程概要的分页结果，NextPageToken 为空表示没有下一页
+	WorkflowSummaryPage struct {
+		Summaries     []*WorkflowSummary `json:"summaries"`
+		NextPageToken []byte             `json:"nextPageToken,omitempty"`
+	}
+
+	// WorkflowPage 分页查询的结果，NextPageToken 为空表示没有下一页
+	WorkflowPage struct {
+		Executions    []*WorkflowExecution `json:"executions"`
+		NextPageToken []byte               `json:"nextPageToken,omitempty"`
+	}
+)
+
+// List 根据可见性查询语句分页获取流程，如：projectName = 'x' AND ExecutionStatus = 'Failed'
+// pageSize 为0时使用服务端默认值，nextPageToken 为上一页返回的值，第一页传nil
+func (su *startUp) List(ctx context.Context, query string, pageSize int, nextPageToken []byte) (*WorkflowPage, error) {
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	resp, err := temporalClient.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+		PageSize:      int32(pageSize),
+		NextPageToken: nextPageToken,
+		Query:         query,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &WorkflowPage{
+		Executions:    make([]*WorkflowExecution, 0, len(resp.GetExecutions())),
+		NextPageToken: resp.GetNextPageToken(),
+	}
+	dataConverter := su.getDataConverter()
+	for _, one := range resp.GetExecutions() {
+		page.Executions = append(page.Executions, newWorkflowExecution(dataConverter, one))
+	}
+	return page, nil
+}
+
+// newWorkflowExecution 转换可见性查询的结果，search attribute 和 memo 解码为普通的值
+// search attribute 不经过codec编码，memo 使用配置的 dataConverter 解码
+func newWorkflowExecution(dataConverter converter.DataConverter, info *workflowpb.WorkflowExecutionInfo) *WorkflowExecution {
+	exe := &WorkflowExecution{
+		WorkflowId:       info.GetExecution().GetWorkflowId(),
+		RunId:            info.GetExecution().GetRunId(),
+		WorkflowType:     info.GetType().GetName(),
+		TaskQueue:        info.GetTaskQueue(),
+		Status:           info.GetStatus(),
+		SearchAttributes: make(map[string]interface{}),
+		Memo:             make(map[string]interface{}),
+	}
+	if info.GetStartTime() != nil {
+		exe.StartTime = info.GetStartTime().AsTime()
+	}
+	if info.GetCloseTime() != nil {
+		exe.CloseTime = info.GetCloseTime().AsTime()
+	}
+
+	for key, payload := range info.GetSearchAttributes().GetIndexedFields() {
+		var val interface{}
+		if err := converter.GetDefaultDataConverter().FromPayload(payload, &val); err != nil {
+			logs.DefaultLogger().Error("List decode search attribute error:", key, err)
+			continue
+		}
+		exe.SearchAttributes[key] = val
+	}
+	for key, payload := range info.GetMemo().GetFields() {
+		var val interface{}
+		if err := dataConverter.FromPayload(payload, &val); err != nil {
+			logs.DefaultLogger().Error("List decode memo error:", key, err)
+			continue
+		}
+		exe.Memo[key] = val
+	}
+	return exe
+}
+
+// ListWorkflows 根据过滤条件分页获取流程概要
+// 失败、超时、终止的流程会并发查询结束事件获取失败原因
+func (su *startUp) ListWorkflows(ctx context.Context, filter *WorkflowFilter) (*WorkflowSummaryPage, error) {
+	if filter == nil {
+		filter = new(WorkflowFilter)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	query, err := su.getFilterQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+	page, err := su.List(ctx, query, filter.PageSize, filter.NextPageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+
+	summaryPage := &WorkflowSummaryPage{
+		Summaries:     make([]*WorkflowSummary, 0, len(page.Executions)),
+		NextPageToken: page.NextPageToken,
+	}
+	for _, one := range page.Executions {
+		summary := &WorkflowSummary{
+			WorkflowExecution: one,
+			DslName:           conv.String(one.Memo[dsl.DslNameMemoKey]),
+		}
+		if !one.StartTime.IsZero() {
+			if one.CloseTime.IsZero() {
+				summary.Duration = time.Since(one.StartTime)
+			} else {
+				summary.Duration = one.CloseTime.Sub(one.StartTime)
+			}
+		}
+		summaryPage.Summaries = append(summaryPage.Summaries, summary)
+	}
+	fillFailureMessages(ctx, temporalClient, summaryPage.Summaries)
+	return summaryPage, nil
+}
+
+// fillFailureMessages 失败、超时、终止的流程查询结束事件获取失败原因，最多 failureMessageWorkers 个并发
+func fillFailureMessages(ctx context.Context, temporalClient client.Client, summaries []*WorkflowSummary) {
+	limit := make(chan struct{}, failureMessageWorkers)
+	var wg sync.WaitGroup
+	for _, summary := range summaries {
+		switch summary.Status {
+		case enums.WORKFLOW_EXECUTION_STATUS_FAILED, enums.WORKFLOW_EXECUTION_STATUS_TIMED_OUT,
+			enums.WORKFLOW_EXECUTION_STATUS_TERMINATED:
+		default:
+			continue
+		}
+		wg.Add(1)
+		limit <- struct{}{}
+		go func(summary *WorkflowSummary) {
+			defer func() {
+				<-limit
+				wg.Done()
+			}()
+			summary.FailureMessage = getFailureMessage(ctx, temporalClient, summary.WorkflowId, summary.RunId)
+		}(summary)
+	}
+	wg.Wait()
+}
+
+// CountWorkflows 获取满足过滤条件的流程数量，分页参数不生效
+func (su *startUp) CountWorkflows(ctx context.Context, filter *WorkflowFilter) (int64, error) {
+	if filter == nil {
+		filter = new(WorkflowFilter)
+	}
+	query, err := su.getFilterQuery(filter)
+	if err != nil {
+		return 0, err
+	}
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return 0, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	resp, err := temporalClient.CountWorkflow(ctx, &workflowservice.CountWorkflowExecutionsRequest{
+		Query: query,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetCount(), nil
+}
+
+// getFilterQuery 将过滤条件转换为可见性查询语句，search attribute 的名字不合法时返回错误
+func (su *startUp) getFilterQuery(filter *WorkflowFilter) (string, error) {
+	condList := make([]string, 0)
+
+	taskQueue := filter.TaskQueue
+	if taskQueue == "" {
+		taskQueue = su.cfg.TaskQueueName
+	}
+	if taskQueue != "" {
+		condList = append(condList, fmt.Sprintf("TaskQueue = %s", getQueryValue(taskQueue)))
+	}
+	if filter.WorkflowType != "" {
+		condList = append(condList, fmt.Sprintf("WorkflowType = %s", getQueryValue(filter.WorkflowType)))
+	}
+	if len(filter.Status) > 0 {
+		statusList := make([]string, 0, len(filter.Status))
+		for _, one := range filter.Status {
+			statusList = append(statusList, getQueryValue(one.String()))
+		}
+		condList = append(condList, fmt.Sprintf("ExecutionStatus IN (%s)", strings.Join(statusList, ", ")))
+	}
+	if !filter.StartTimeFrom.IsZero() {
+		condList = append(condList, fmt.Sprintf("StartTime >= %s", getQueryValue(filter.StartTimeFrom)))
+	}
+	if !filter.StartTimeTo.IsZero() {
+		condList = append(condList, fmt.Sprintf("StartTime < %s", getQueryValue(filter.StartTimeTo)))
+	}
+	if !filter.CloseTimeFrom.IsZero() {
+		condList = append(condList, fmt.Sprintf("CloseTime >= %s", getQueryValue(filter.CloseTimeFrom)))
+	}
+	if !filter.CloseTimeTo.IsZero() {
+		condList = append(condList, fmt.Sprintf("CloseTime < %s", getQueryValue(filter.CloseTimeTo)))
+	}
+
+	//按名字排序，保证生成的语句是确定的
+	keyList := make([]string, 0, len(filter.SearchAttributes))
+	for key := range filter.SearchAttributes {
+		keyList = append(keyList, key)
+	}
+	sort.Strings(keyList)
+	for _, key := range keyList {
+		//名字直接拼接到语句中，只允许字母、数字、下划线，避免注入查询条件
+		if !searchAttributeNameRegexp.MatchString(key) {
+			return "", fmt.Errorf("invalid search attribute name: %s", key)
+		}
+		condList = append(condList, fmt.Sprintf("%s = %s", key, getQueryValue(filter.SearchAttributes[key])))
+	}
+	return strings.Join(condList, " AND "), nil
+}
+
+// getQueryValue 转换为查询语句中的值，字符串和时间需要加引号
+func getQueryValue(val interface{}) string {
+	switch v := val.(type) {
+	case int, int32, int64, float32, float64, bool:
+		return conv.String(v)
+	case time.Time:
+		return fmt.Sprintf("'%s'", v.UTC().Format(time.RFC3339Nano))
+	}
+	//先转义反斜杠，避免值末尾的反斜杠把结束的引号转义
+	str := strings.ReplaceAll(conv.String(val), "\\", "\\\\")
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(str, "'", "\\'"))
+}
+
+// getFailureMessage 从结束事件中获取失败原因
+func getFailureMessage(ctx context.Context, temporalClient client.Client, workflowId, runId string) string {
+	iter := temporalClient.GetWorkflowHistory(ctx, workflowId, runId, false, enums.HISTORY_EVENT_FILTER_TYPE_CLOSE_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			logs.DefaultLogger().Error("ListWorkflows get close event error:", workflowId, err)
+			return ""
+		}
+		if attr := event.GetWorkflowExecutionFailedEventAttributes(); attr != nil {
+			return attr.GetFailure().GetMessage()
+		}
+		if attr := event.GetWorkflowExecutionTerminatedEventAttributes(); attr != nil {
+			return attr.GetReason()
+		}
+		if attr := event.GetWorkflowExecutionTimedOutEventAttributes(); attr != nil {
+			return "workflow execution timed out"
+		}
+	}
+	return ""
+}
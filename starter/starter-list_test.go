@@ -0,0 +1,117 @@
+package starter
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetFilterQuery(t *testing.T) {
+	su := &startUp{cfg: &Config{TaskQueueName: "queue"}}
+	t0 := time.Date(2024, 1, 1, 8, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	tests := []struct {
+		name   string
+		filter *WorkflowFilter
+		want   string
+		hasErr bool
+	}{
+		{name: "default task queue", filter: &WorkflowFilter{}, want: "TaskQueue = 'queue'"},
+		{name: "all fields", filter: &WorkflowFilter{
+			TaskQueue:    "other",
+			WorkflowType: "DslWorkflow",
+			Status: []enums.WorkflowExecutionStatus{enums.WORKFLOW_EXECUTION_STATUS_RUNNING,
+				enums.WORKFLOW_EXECUTION_STATUS_FAILED},
+			StartTimeFrom: t0,
+			CloseTimeTo:   t0.Add(time.Hour),
+			SearchAttributes: map[string]interface{}{
+				"status": "deleted",
+				"cdId":   12,
+			},
+		}, want: "TaskQueue = 'other' AND WorkflowType = 'DslWorkflow' AND ExecutionStatus IN ('Running', 'Failed')" +
+			" AND StartTime >= '2024-01-01T00:00:00Z' AND CloseTime < '2024-01-01T01:00:00Z' AND cdId = 12 AND status = 'deleted'"},
+		{name: "quote", filter: &WorkflowFilter{SearchAttributes: map[string]interface{}{"owner": "o'neil"}},
+			want: `TaskQueue = 'queue' AND owner = 'o\'neil'`},
+		{name: "trailing backslash", filter: &WorkflowFilter{SearchAttributes: map[string]interface{}{"owner": `a\`}},
+			want: `TaskQueue = 'queue' AND owner = 'a\\'`},
+		{name: "backslash before quote", filter: &WorkflowFilter{SearchAttributes: map[string]interface{}{"owner": `a\' OR 1=1 --`}},
+			want: `TaskQueue = 'queue' AND owner = 'a\\\' OR 1=1 --'`},
+		{name: "invalid key", filter: &WorkflowFilter{SearchAttributes: map[string]interface{}{"a = 'b' OR c": "d"}}, hasErr: true},
+		{name: "key starts with digit", filter: &WorkflowFilter{SearchAttributes: map[string]interface{}{"1a": "d"}}, hasErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := su.getFilterQuery(tt.filter)
+			if tt.hasErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFillFailureMessages(t *testing.T) {
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	c := &mocks.Client{}
+	c.On("GetWorkflowHistory", mock.Anything, mock.Anything, mock.Anything, false,
+		enums.HISTORY_EVENT_FILTER_TYPE_CLOSE_EVENT).
+		Return(func(_ context.Context, workflowId, _ string, _ bool, _ enums.HistoryEventFilterType) client.HistoryEventIterator {
+			lock.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+			time.Sleep(10 * time.Millisecond)
+			lock.Lock()
+			running--
+			lock.Unlock()
+			return &testHistoryIterator{events: []*historypb.HistoryEvent{
+				newTestEvent(1, enums.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED, &historypb.WorkflowExecutionFailedEventAttributes{
+					Failure: &failurepb.Failure{Message: workflowId + " failed"}}),
+			}}
+		})
+
+	summaries := make([]*WorkflowSummary, 0)
+	for i := 0; i < 3*failureMessageWorkers; i++ {
+		status := enums.WORKFLOW_EXECUTION_STATUS_FAILED
+		if i%3 == 0 {
+			status = enums.WORKFLOW_EXECUTION_STATUS_COMPLETED
+		}
+		summaries = append(summaries, &WorkflowSummary{WorkflowExecution: &WorkflowExecution{
+			WorkflowId: fmt.Sprintf("wf-%d", i),
+			Status:     status,
+		}})
+	}
+	fillFailureMessages(context.Background(), c, summaries)
+
+	for _, summary := range summaries {
+		want := ""
+		if summary.Status == enums.WORKFLOW_EXECUTION_STATUS_FAILED {
+			want = summary.WorkflowId + " failed"
+		}
+		if summary.FailureMessage != want {
+			t.Fatalf("%s failure message %q, want %q", summary.WorkflowId, summary.FailureMessage, want)
+		}
+	}
+	if maxRunning < 2 || maxRunning > failureMessageWorkers {
+		t.Fatalf("unexpected concurrency: %d", maxRunning)
+	}
+	c.AssertNumberOfCalls(t, "GetWorkflowHistory", 2*failureMessageWorkers)
+}
@@ -0,0 +1,278 @@
+package starter
+
+import (
+	"context"
+	"fmt"
+	"github.com/tianlin0/plat-lib/logs"
+	"go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StepStatusScheduled = "scheduled"
+	StepStatusStarted   = "started"
+	StepStatusCompleted = "completed"
+	StepStatusFailed    = "failed"
+	StepStatusTimedOut  = "timedOut"
+	StepStatusCanceled  = "canceled"
+)
+
+var (
+	// defaultActivityIdRegexp temporal默认生成的activity id为数字，不是DSL中的id
+	defaultActivityIdRegexp = regexp.MustCompile(`^\d+$`)
+)
+
+// StepLog 流程中一个activity的执行记录
+type StepLog struct {
+	TaskQueue    string `json:"taskQueue"`
+	WorkflowId   string `json:"workflowId"`
+	RunId        string `json:"runId"`
+	StepId       string `json:"stepId,omitempty"` //DSL中activity的id，旧的流程没有记录时为空
+	ActivityId   string `json:"activityId"`       //temporal的activity id，重复执行时为 id#2、id#3...
+	ActivityType string `json:"activityType"`
+	Status       string `json:"status"`
+
+	Input            []interface{} `json:"input,omitempty"`
+	Output           []interface{} `json:"output,omitempty"`
+	Attempt          int32         `json:"attempt"`                    //当前或者最后一次执行是第几次
+	LastFailure      string        `json:"lastFailure,omitempty"`      //失败、超时的原因，或者重试前最后一次失败的原因
+	HeartbeatDetails []interface{} `json:"heartbeatDetails,omitempty"` //最后一次心跳的内容
+	TimeoutType      string        `json:"timeoutType,omitempty"`
+
+	ScheduleToCloseTimeout time.Duration `json:"scheduleToCloseTimeout,omitempty"`
+	StartToCloseTimeout    time.Duration `json:"startToCloseTimeout,omitempty"`
+	HeartbeatTimeout       time.Duration `json:"heartbeatTimeout,omitempty"`
+
+	ScheduledTime time.Time `json:"scheduledTime"`
+	StartedTime   time.Time `json:"startedTime,omitempty"`
+	CloseTime     time.Time `json:"closeTime,omitempty"`
+	UseTime       int64     `json:"useTime"` //使用了多长时间，毫秒
+}
+
+// GetAllLogList 获取流程以及所有子流程中activity的执行记录，子流程的记录在父流程之后
+// 输入、输出和心跳内容都已经解码，运行中的activity会补充重试次数和心跳信息
+func (su *startUp) GetAllLogList(taskQueue string, workflowId, runId string) ([]*StepLog, error) {
+	if taskQueue == "" {
+		return nil, fmt.Errorf("taskQueue is empty")
+	}
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	return getWorkflowStepLogs(context.Background(), temporalClient, su.getDataConverter(), taskQueue, workflowId, runId)
+}
+
+// HistorySource 获取流程的历史事件，可以从temporal服务获取，也可以从导出的历史文件中获取
+type HistorySource interface {
+	GetHistory(ctx context.Context, workflowId, runId string) ([]*historypb.HistoryEvent, error)
+}
+
+// clientHistorySource 从temporal服务获取历史事件
+type clientHistorySource struct {
+	temporalClient client.Client
+}
+
+func (c *clientHistorySource) GetHistory(ctx context.Context, workflowId, runId string) ([]*historypb.HistoryEvent, error) {
+	eventList := make([]*historypb.HistoryEvent, 0)
+	iter := c.temporalClient.GetWorkflowHistory(ctx, workflowId, runId, false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		eventList = append(eventList, event)
+	}
+	return eventList, nil
+}
+
+// getWorkflowStepLogs 获取流程以及子流程中的activity记录，子流程并发获取
+func getWorkflowStepLogs(ctx context.Context, temporalClient client.Client, dataConverter converter.DataConverter,
+	taskQueue string, workflowId, runId string) ([]*StepLog, error) {
+	eventList, err := (&clientHistorySource{temporalClient: temporalClient}).GetHistory(ctx, workflowId, runId)
+	if err != nil {
+		return nil, err
+	}
+	stepList, childList, running := parseStepLogs(dataConverter, eventList, taskQueue, workflowId, runId)
+
+	if running {
+		fillPendingSteps(ctx, temporalClient, dataConverter, workflowId, runId, stepList)
+	}
+
+	//子流程并发获取，按启动顺序合并
+	childSteps := make([][]*StepLog, len(childList))
+	childErrs := make([]error, len(childList))
+	var wg sync.WaitGroup
+	for i, child := range childList {
+		wg.Add(1)
+		go func(i int, child *common.WorkflowExecution) {
+			defer wg.Done()
+			childSteps[i], childErrs[i] = getWorkflowStepLogs(ctx, temporalClient, dataConverter, taskQueue, child.GetWorkflowId(), child.GetRunId())
+		}(i, child)
+	}
+	wg.Wait()
+
+	for i := range childList {
+		if childErrs[i] != nil {
+			return nil, childErrs[i]
+		}
+		stepList = append(stepList, childSteps[i]...)
+	}
+	return stepList, nil
+}
+
+// parseStepLogs 遍历一次历史事件，按 ScheduledEventId 建立索引得到所有activity的记录
+// 同时返回启动的子流程，以及流程是否还在运行
+// 输入、输出等使用 dataConverter 解码
+func parseStepLogs(dataConverter converter.DataConverter, eventList []*historypb.HistoryEvent,
+	taskQueue string, workflowId, runId string) ([]*StepLog, []*common.WorkflowExecution, bool) {
+	stepList := make([]*StepLog, 0)
+	stepMap := make(map[int64]*StepLog)
+	childList := make([]*common.WorkflowExecution, 0)
+	running := true
+
+	for _, event := range eventList {
+		if attr := event.GetActivityTaskScheduledEventAttributes(); attr != nil {
+			step := &StepLog{
+				TaskQueue:              taskQueue,
+				WorkflowId:             workflowId,
+				RunId:                  runId,
+				StepId:                 getStepId(attr.GetActivityId()),
+				ActivityId:             attr.GetActivityId(),
+				ActivityType:           attr.GetActivityType().GetName(),
+				Status:                 StepStatusScheduled,
+				Input:                  decodePayloads(dataConverter, attr.GetInput()),
+				ScheduleToCloseTimeout: attr.GetScheduleToCloseTimeout().AsDuration(),
+				StartToCloseTimeout:    attr.GetStartToCloseTimeout().AsDuration(),
+				HeartbeatTimeout:       attr.GetHeartbeatTimeout().AsDuration(),
+				ScheduledTime:          event.GetEventTime().AsTime(),
+			}
+			stepMap[event.GetEventId()] = step
+			stepList = append(stepList, step)
+			continue
+		}
+		if attr := event.GetActivityTaskStartedEventAttributes(); attr != nil {
+			if step, ok := stepMap[attr.GetScheduledEventId()]; ok {
+				step.Status = StepStatusStarted
+				step.StartedTime = event.GetEventTime().AsTime()
+				step.Attempt = attr.GetAttempt()
+				step.LastFailure = attr.GetLastFailure().GetMessage()
+			}
+			continue
+		}
+		if attr := event.GetActivityTaskCompletedEventAttributes(); attr != nil {
+			if step, ok := stepMap[attr.GetScheduledEventId()]; ok {
+				step.Status = StepStatusCompleted
+				step.Output = decodePayloads(dataConverter, attr.GetResult())
+				step.setCloseTime(event)
+			}
+			continue
+		}
+		if attr := event.GetActivityTaskFailedEventAttributes(); attr != nil {
+			if step, ok := stepMap[attr.GetScheduledEventId()]; ok {
+				step.Status = StepStatusFailed
+				step.LastFailure = attr.GetFailure().GetMessage()
+				step.setCloseTime(event)
+			}
+			continue
+		}
+		if attr := event.GetActivityTaskTimedOutEventAttributes(); attr != nil {
+			if step, ok := stepMap[attr.GetScheduledEventId()]; ok {
+				timeoutInfo := attr.GetFailure().GetTimeoutFailureInfo()
+				step.Status = StepStatusTimedOut
+				step.LastFailure = attr.GetFailure().GetMessage()
+				step.TimeoutType = timeoutInfo.GetTimeoutType().String()
+				step.HeartbeatDetails = decodePayloads(dataConverter, timeoutInfo.GetLastHeartbeatDetails())
+				step.setCloseTime(event)
+			}
+			continue
+		}
+		if attr := event.GetActivityTaskCanceledEventAttributes(); attr != nil {
+			if step, ok := stepMap[attr.GetScheduledEventId()]; ok {
+				step.Status = StepStatusCanceled
+				step.Output = decodePayloads(dataConverter, attr.GetDetails())
+				step.setCloseTime(event)
+			}
+			continue
+		}
+		if attr := event.GetChildWorkflowExecutionStartedEventAttributes(); attr != nil {
+			childList = append(childList, attr.GetWorkflowExecution())
+			continue
+		}
+		if isWorkflowCloseEvent(event) {
+			running = false
+		}
+	}
+	return stepList, childList, running
+}
+
+// isWorkflowCloseEvent 是否为流程结束的事件
+func isWorkflowCloseEvent(event *historypb.HistoryEvent) bool {
+	switch event.GetEventType() {
+	case enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED, enums.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED,
+		enums.EVENT_TYPE_WORKFLOW_EXECUTION_TIMED_OUT, enums.EVENT_TYPE_WORKFLOW_EXECUTION_CANCELED,
+		enums.EVENT_TYPE_WORKFLOW_EXECUTION_TERMINATED, enums.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW:
+		return true
+	}
+	return false
+}
+
+// fillPendingSteps 运行中的activity，从流程详情中补充重试次数、最后一次失败和心跳信息
+func fillPendingSteps(ctx context.Context, temporalClient client.Client, dataConverter converter.DataConverter,
+	workflowId, runId string, stepList []*StepLog) {
+	descResp, err := temporalClient.DescribeWorkflowExecution(ctx, workflowId, runId)
+	if err != nil {
+		logs.DefaultLogger().Error("GetAllLogList describe error:", workflowId, err)
+		return
+	}
+	for _, pending := range descResp.GetPendingActivities() {
+		for _, step := range stepList {
+			if step.ActivityId != pending.GetActivityId() || !step.CloseTime.IsZero() {
+				continue
+			}
+			step.Attempt = pending.GetAttempt()
+			step.HeartbeatDetails = decodePayloads(dataConverter, pending.GetHeartbeatDetails())
+			if pending.GetLastFailure() != nil {
+				step.LastFailure = pending.GetLastFailure().GetMessage()
+			}
+		}
+	}
+}
+
+func (step *StepLog) setCloseTime(event *historypb.HistoryEvent) {
+	step.CloseTime = event.GetEventTime().AsTime()
+	step.UseTime = step.CloseTime.Sub(step.ScheduledTime).Milliseconds()
+}
+
+// getStepId 从activity id中获取DSL中的id，去掉重复执行时的 #n 后缀
+func getStepId(activityId string) string {
+	if defaultActivityIdRegexp.MatchString(activityId) {
+		return ""
+	}
+	if i := strings.LastIndex(activityId, "#"); i > 0 {
+		return activityId[:i]
+	}
+	return activityId
+}
+
+// decodePayloads 解码为普通的值，解码失败的保留原始内容
+func decodePayloads(dataConverter converter.DataConverter, payloads *common.Payloads) []interface{} {
+	if payloads == nil || len(payloads.GetPayloads()) == 0 {
+		return nil
+	}
+	valList := make([]interface{}, 0, len(payloads.GetPayloads()))
+	for _, one := range payloads.GetPayloads() {
+		var val interface{}
+		if err := dataConverter.FromPayload(one, &val); err != nil {
+			val = string(one.GetData())
+		}
+		valList = append(valList, val)
+	}
+	return valList
+}
@@ -0,0 +1,91 @@
+package starter
+
+import (
+	"context"
+	"fmt"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/converter"
+	"testing"
+)
+
+// newTestScheduled activity开始调度的历史事件
+func newTestScheduled(id int64, activityId string) *historypb.HistoryEvent {
+	return newTestEvent(id, enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED, &historypb.ActivityTaskScheduledEventAttributes{
+		ActivityId:   activityId,
+		ActivityType: &commonpb.ActivityType{Name: "queue/deploy"},
+	})
+}
+
+// newTestActivityFailed activity执行失败的历史事件
+func newTestActivityFailed(id int64, scheduledId int64, message string) *historypb.HistoryEvent {
+	return newTestEvent(id, enums.EVENT_TYPE_ACTIVITY_TASK_FAILED, &historypb.ActivityTaskFailedEventAttributes{
+		ScheduledEventId: scheduledId,
+		Failure:          &failurepb.Failure{Message: message},
+	})
+}
+
+func TestGetWorkflowStepLogs(t *testing.T) {
+	dataConverter := converter.GetDefaultDataConverter()
+	result, err := dataConverter.ToPayloads(map[string]interface{}{"ok": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestHistoryClient(
+		&testWorkflowRun{workflowId: "root", runId: "r1", events: []*historypb.HistoryEvent{
+			newTestScheduled(1, "deploy"),
+			newTestActivityFailed(2, 1, "connect refused"),
+			newTestScheduled(3, "deploy#2"),
+			newTestEvent(4, enums.EVENT_TYPE_ACTIVITY_TASK_COMPLETED,
+				&historypb.ActivityTaskCompletedEventAttributes{ScheduledEventId: 3, Result: result}),
+			newTestScheduled(5, "6"),
+			newTestChildStarted(7, "notify-child", "c1"),
+			newTestEvent(9, enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED, nil),
+		}},
+		&testWorkflowRun{workflowId: "notify-child", runId: "c1", events: []*historypb.HistoryEvent{
+			newTestScheduled(1, "notify"),
+			newTestActivityFailed(2, 1, "notify failed"),
+			newTestEvent(3, enums.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED,
+				&historypb.WorkflowExecutionFailedEventAttributes{Failure: &failurepb.Failure{Message: "notify failed"}}),
+		}},
+	)
+
+	stepList, err := getWorkflowStepLogs(context.Background(), c, dataConverter, "queue", "root", "r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stepList) != 4 {
+		t.Fatalf("unexpected steps: %d", len(stepList))
+	}
+	// 重复执行的activity使用同一个 StepId，按调度顺序排列
+	first, second, unnamed, child := stepList[0], stepList[1], stepList[2], stepList[3]
+	if first.ActivityId != "deploy" || first.StepId != "deploy" || first.Status != StepStatusFailed ||
+		first.LastFailure != "connect refused" {
+		t.Fatalf("unexpected first run: %+v", first)
+	}
+	if second.ActivityId != "deploy#2" || second.StepId != "deploy" || second.Status != StepStatusCompleted ||
+		len(second.Output) != 1 || second.UseTime != 1000 {
+		t.Fatalf("unexpected second run: %+v", second)
+	}
+	if unnamed.StepId != "" || unnamed.Status != StepStatusScheduled {
+		t.Fatalf("unexpected unnamed step: %+v", unnamed)
+	}
+	// 子流程的记录在父流程之后
+	if child.WorkflowId != "notify-child" || child.RunId != "c1" || child.StepId != "notify" ||
+		child.Status != StepStatusFailed || child.LastFailure != "notify failed" {
+		t.Fatalf("unexpected child step: %+v", child)
+	}
+
+	lost := newTestHistoryClient(
+		&testWorkflowRun{workflowId: "root", runId: "r1", events: []*historypb.HistoryEvent{
+			newTestChildStarted(1, "lost-child", "c2"),
+			newTestEvent(2, enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED, nil),
+		}},
+		&testWorkflowRun{workflowId: "lost-child", runId: "c2", err: fmt.Errorf("workflow not found")},
+	)
+	if _, err = getWorkflowStepLogs(context.Background(), lost, dataConverter, "queue", "root", "r1"); err == nil {
+		t.Fatal("expected child history error")
+	}
+}
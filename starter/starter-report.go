@@ -0,0 +1,486 @@
+package starter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tianlin0/plat-lib/logs"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"html/template"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	TimelineBarWorkflow = "workflow" //流程从启动到结束
+	TimelineBarActivity = "activity"
+	TimelineBarTimer    = "timer" //定时器，如轮询的间隔
+	TimelineBarWait     = "wait"  //没有activity和定时器的空档，一般是在等待signal、审批等
+
+	TimelineMarkerSignal  = "signal"
+	TimelineMarkerUpdate  = "update"
+	TimelineMarkerRetry   = "retry"
+	TimelineMarkerFailure = "failure"
+
+	timelineWaitRowName = "wait"
+	minTimelineWaitGap  = time.Second //小于这个时间的空档不显示，一般是workflow task的处理时间
+)
+
+var (
+	errHistoryNotFound = errors.New("history not found")
+)
+
+type (
+	// Timeline 流程以及子流程的执行时间线，可以输出为JSON或者HTML甘特图
+	Timeline struct {
+		WorkflowId string         `json:"workflowId"`
+		RunId      string         `json:"runId"`
+		StartTime  time.Time      `json:"startTime"`
+		EndTime    time.Time      `json:"endTime"`
+		Rows       []*TimelineRow `json:"rows"`
+
+		dataConverter converter.DataConverter
+	}
+
+	// TimelineRow 时间线中的一行，每个流程依次为：流程行、每个DSL activity id一行、等待行
+	TimelineRow struct {
+		WorkflowId string            `json:"workflowId"`
+		RunId      string            `json:"runId"`
+		Depth      int               `json:"depth"` //子流程的层级，根流程为0
+		Name       string            `json:"name"`  //DSL中activity的id，没有时为activity类型
+		Lane       int               `json:"lane"`  //同一个流程中时间有重叠的activity在不同的泳道，表示并行执行
+		Bars       []*TimelineBar    `json:"bars"`
+		Markers    []*TimelineMarker `json:"markers,omitempty"`
+	}
+
+	// TimelineBar 一段执行时间，同一个id重复执行时一行中有多段
+	TimelineBar struct {
+		Kind    string    `json:"kind"`
+		Name    string    `json:"name"`
+		Start   time.Time `json:"start"`
+		End     time.Time `json:"end"`
+		Status  string    `json:"status,omitempty"`
+		Attempt int32     `json:"attempt,omitempty"`
+		Message string    `json:"message,omitempty"`
+	}
+
+	// TimelineMarker 某个时间点发生的事件，如收到signal、重试、失败
+	TimelineMarker struct {
+		Kind    string    `json:"kind"`
+		Name    string    `json:"name"`
+		Time    time.Time `json:"time"`
+		Message string    `json:"message,omitempty"`
+	}
+
+	// FileHistorySource 从导出的历史JSON文件中获取历史事件，key为workflowId，value为文件路径
+	// 没有对应文件的子流程会被跳过，根流程必须存在
+	FileHistorySource map[string]string
+)
+
+func (f FileHistorySource) GetHistory(_ context.Context, workflowId, _ string) ([]*historypb.HistoryEvent, error) {
+	path, ok := f[workflowId]
+	if !ok {
+		return nil, fmt.Errorf("%s %w", workflowId, errHistoryNotFound)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	history, err := client.HistoryFromJSON(file, client.HistoryJSONOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return history.GetEvents(), nil
+}
+
+// GetTimeline 从temporal服务获取流程以及所有子流程的执行时间线
+func (su *startUp) GetTimeline(ctx context.Context, workflowId, runId string) (*Timeline, error) {
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	tl := newTimeline(su.getDataConverter(), workflowId, runId)
+	return tl.build(ctx, &clientHistorySource{temporalClient: temporalClient})
+}
+
+// BuildTimeline 根据历史事件生成流程以及所有子流程的执行时间线
+// 离线时可以使用 FileHistorySource 读取通过 tctl/temporal cli 导出的历史文件
+// 历史中的payload使用默认的 DataConverter 解码
+func BuildTimeline(ctx context.Context, source HistorySource, workflowId, runId string) (*Timeline, error) {
+	return newTimeline(converter.GetDefaultDataConverter(), workflowId, runId).build(ctx, source)
+}
+
+func newTimeline(dataConverter converter.DataConverter, workflowId, runId string) *Timeline {
+	return &Timeline{
+		WorkflowId:    workflowId,
+		RunId:         runId,
+		Rows:          make([]*TimelineRow, 0),
+		dataConverter: dataConverter,
+	}
+}
+
+// build 添加所有流程的行，并计算整体的开始和结束时间
+func (tl *Timeline) build(ctx context.Context, source HistorySource) (*Timeline, error) {
+	if err := tl.addWorkflow(ctx, source, tl.WorkflowId, tl.RunId, 0); err != nil {
+		return nil, err
+	}
+
+	for _, row := range tl.Rows {
+		for _, bar := range row.Bars {
+			if tl.StartTime.IsZero() || bar.Start.Before(tl.StartTime) {
+				tl.StartTime = bar.Start
+			}
+			if bar.End.After(tl.EndTime) {
+				tl.EndTime = bar.End
+			}
+		}
+	}
+	return tl, nil
+}
+
+// addWorkflow 添加一个流程的所有行，然后依次添加子流程
+func (tl *Timeline) addWorkflow(ctx context.Context, source HistorySource, workflowId, runId string, depth int) error {
+	eventList, err := source.GetHistory(ctx, workflowId, runId)
+	if err != nil {
+		if depth > 0 && errors.Is(err, errHistoryNotFound) {
+			logs.DefaultLogger().Error("BuildTimeline skip child:", err)
+			return nil
+		}
+		return err
+	}
+	if len(eventList) == 0 {
+		return fmt.Errorf("%s %w", workflowId, errHistoryNotFound)
+	}
+
+	stepList, childList, running := parseStepLogs(tl.dataConverter, eventList, "", workflowId, runId)
+
+	startTime := eventList[0].GetEventTime().AsTime()
+	endTime := eventList[len(eventList)-1].GetEventTime().AsTime()
+	status := enums.WORKFLOW_EXECUTION_STATUS_RUNNING.String()
+	if running {
+		endTime = time.Now()
+	} else {
+		status = getWorkflowCloseStatus(eventList[len(eventList)-1])
+	}
+
+	newRow := func(name string) *TimelineRow {
+		return &TimelineRow{
+			WorkflowId: workflowId,
+			RunId:      runId,
+			Depth:      depth,
+			Name:       name,
+			Bars:       make([]*TimelineBar, 0),
+			Markers:    make([]*TimelineMarker, 0),
+		}
+	}
+
+	workflowRow := newRow(workflowId)
+	workflowRow.Bars = append(workflowRow.Bars, &TimelineBar{
+		Kind:   TimelineBarWorkflow,
+		Name:   workflowId,
+		Start:  startTime,
+		End:    endTime,
+		Status: status,
+	})
+
+	//每个DSL activity id一行，按第一次执行的顺序
+	busyList := make([]*TimelineBar, 0)
+	stepRows := make([]*TimelineRow, 0)
+	stepRowMap := make(map[string]*TimelineRow)
+	for _, step := range stepList {
+		name := step.StepId
+		if name == "" {
+			name = step.ActivityType
+		}
+		row, ok := stepRowMap[name]
+		if !ok {
+			row = newRow(name)
+			stepRowMap[name] = row
+			stepRows = append(stepRows, row)
+		}
+
+		bar := &TimelineBar{
+			Kind:    TimelineBarActivity,
+			Name:    step.ActivityId,
+			Start:   step.ScheduledTime,
+			End:     step.CloseTime,
+			Status:  step.Status,
+			Attempt: step.Attempt,
+			Message: step.LastFailure,
+		}
+		if bar.End.IsZero() {
+			bar.End = endTime
+		}
+		row.Bars = append(row.Bars, bar)
+		busyList = append(busyList, bar)
+
+		if step.Attempt > 1 && !step.StartedTime.IsZero() {
+			row.Markers = append(row.Markers, &TimelineMarker{
+				Kind:    TimelineMarkerRetry,
+				Name:    step.ActivityId,
+				Time:    step.StartedTime,
+				Message: fmt.Sprintf("attempt %d: %s", step.Attempt, step.LastFailure),
+			})
+		}
+		if step.Status == StepStatusFailed || step.Status == StepStatusTimedOut {
+			row.Markers = append(row.Markers, &TimelineMarker{
+				Kind:    TimelineMarkerFailure,
+				Name:    step.ActivityId,
+				Time:    step.CloseTime,
+				Message: step.LastFailure,
+			})
+		}
+	}
+	setTimelineLanes(stepRows)
+
+	//定时器、signal、update，以及没有任何执行的空档
+	waitRow := newRow(timelineWaitRowName)
+	timerMap := make(map[string]*TimelineBar)
+	for _, event := range eventList {
+		eventTime := event.GetEventTime().AsTime()
+		if attr := event.GetTimerStartedEventAttributes(); attr != nil {
+			bar := &TimelineBar{
+				Kind:  TimelineBarTimer,
+				Name:  attr.GetTimerId(),
+				Start: eventTime,
+				End:   endTime,
+			}
+			timerMap[attr.GetTimerId()] = bar
+			waitRow.Bars = append(waitRow.Bars, bar)
+			busyList = append(busyList, bar)
+			continue
+		}
+		if attr := event.GetTimerFiredEventAttributes(); attr != nil {
+			if bar, ok := timerMap[attr.GetTimerId()]; ok {
+				bar.End = eventTime
+				bar.Status = "fired"
+			}
+			continue
+		}
+		if attr := event.GetTimerCanceledEventAttributes(); attr != nil {
+			if bar, ok := timerMap[attr.GetTimerId()]; ok {
+				bar.End = eventTime
+				bar.Status = "canceled"
+			}
+			continue
+		}
+		if attr := event.GetWorkflowExecutionSignaledEventAttributes(); attr != nil {
+			waitRow.Markers = append(waitRow.Markers, &TimelineMarker{
+				Kind:    TimelineMarkerSignal,
+				Name:    attr.GetSignalName(),
+				Time:    eventTime,
+				Message: attr.GetIdentity(),
+			})
+			continue
+		}
+		if attr := event.GetWorkflowExecutionUpdateAcceptedEventAttributes(); attr != nil {
+			waitRow.Markers = append(waitRow.Markers, &TimelineMarker{
+				Kind:    TimelineMarkerUpdate,
+				Name:    attr.GetAcceptedRequest().GetInput().GetName(),
+				Time:    eventTime,
+				Message: attr.GetAcceptedRequest().GetMeta().GetIdentity(),
+			})
+		}
+	}
+	waitRow.Bars = append(waitRow.Bars, getTimelineWaitBars(startTime, endTime, busyList, waitRow.Markers)...)
+
+	tl.Rows = append(tl.Rows, workflowRow)
+	tl.Rows = append(tl.Rows, stepRows...)
+	if len(waitRow.Bars) > 0 || len(waitRow.Markers) > 0 {
+		tl.Rows = append(tl.Rows, waitRow)
+	}
+
+	for _, child := range childList {
+		if err = tl.addWorkflow(ctx, source, child.GetWorkflowId(), child.GetRunId(), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getWorkflowCloseStatus 根据结束事件获取流程的状态
+func getWorkflowCloseStatus(event *historypb.HistoryEvent) string {
+	switch event.GetEventType() {
+	case enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED:
+		return enums.WORKFLOW_EXECUTION_STATUS_COMPLETED.String()
+	case enums.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED:
+		return enums.WORKFLOW_EXECUTION_STATUS_FAILED.String()
+	case enums.EVENT_TYPE_WORKFLOW_EXECUTION_TIMED_OUT:
+		return enums.WORKFLOW_EXECUTION_STATUS_TIMED_OUT.String()
+	case enums.EVENT_TYPE_WORKFLOW_EXECUTION_CANCELED:
+		return enums.WORKFLOW_EXECUTION_STATUS_CANCELED.String()
+	case enums.EVENT_TYPE_WORKFLOW_EXECUTION_TERMINATED:
+		return enums.WORKFLOW_EXECUTION_STATUS_TERMINATED.String()
+	case enums.EVENT_TYPE_WORKFLOW_EXECUTION_CONTINUED_AS_NEW:
+		return enums.WORKFLOW_EXECUTION_STATUS_CONTINUED_AS_NEW.String()
+	}
+	return ""
+}
+
+// setTimelineLanes 时间有重叠的行分配到不同的泳道，泳道可以复用
+func setTimelineLanes(rowList []*TimelineRow) {
+	laneEndList := make([]time.Time, 0)
+	for _, row := range rowList {
+		if len(row.Bars) == 0 {
+			continue
+		}
+		start, end := row.Bars[0].Start, row.Bars[0].End
+		for _, bar := range row.Bars {
+			if bar.End.After(end) {
+				end = bar.End
+			}
+		}
+
+		row.Lane = -1
+		for i, laneEnd := range laneEndList {
+			if !laneEnd.After(start) {
+				row.Lane = i
+				laneEndList[i] = end
+				break
+			}
+		}
+		if row.Lane < 0 {
+			row.Lane = len(laneEndList)
+			laneEndList = append(laneEndList, end)
+		}
+	}
+}
+
+// getTimelineWaitBars 获取没有activity和定时器在执行的空档，空档中收到的signal或者update作为名字
+func getTimelineWaitBars(startTime, endTime time.Time, busyList []*TimelineBar, markerList []*TimelineMarker) []*TimelineBar {
+	sortedList := make([]*TimelineBar, len(busyList))
+	copy(sortedList, busyList)
+	sort.SliceStable(sortedList, func(i, j int) bool {
+		return sortedList[i].Start.Before(sortedList[j].Start)
+	})
+
+	waitList := make([]*TimelineBar, 0)
+	addWait := func(start, end time.Time) {
+		if end.Sub(start) < minTimelineWaitGap {
+			return
+		}
+		bar := &TimelineBar{
+			Kind:  TimelineBarWait,
+			Name:  timelineWaitRowName,
+			Start: start,
+			End:   end,
+		}
+		for _, marker := range markerList {
+			if marker.Time.After(start) && !marker.Time.After(end) {
+				bar.Name = fmt.Sprintf("%s %s", marker.Kind, marker.Name)
+				break
+			}
+		}
+		waitList = append(waitList, bar)
+	}
+
+	cursor := startTime
+	for _, bar := range sortedList {
+		if bar.Start.After(cursor) {
+			addWait(cursor, bar.Start)
+		}
+		if bar.End.After(cursor) {
+			cursor = bar.End
+		}
+	}
+	if endTime.After(cursor) {
+		addWait(cursor, endTime)
+	}
+	return waitList
+}
+
+// JSON 输出为JSON格式
+func (tl *Timeline) JSON() ([]byte, error) {
+	return json.MarshalIndent(tl, "", "  ")
+}
+
+// HTML 输出为独立的HTML甘特图，不依赖任何外部资源，可以直接保存为文件查看
+func (tl *Timeline) HTML() ([]byte, error) {
+	total := tl.EndTime.Sub(tl.StartTime)
+	percent := func(d time.Duration) float64 {
+		if total <= 0 {
+			return 0
+		}
+		return float64(d) * 100 / float64(total)
+	}
+
+	tmpl, err := template.New("timeline").Funcs(template.FuncMap{
+		"left": func(t time.Time) string {
+			return fmt.Sprintf("%.3f%%", percent(t.Sub(tl.StartTime)))
+		},
+		"width": func(start, end time.Time) string {
+			return fmt.Sprintf("%.3f%%", percent(end.Sub(start)))
+		},
+		"indent": func(depth int) int {
+			return depth * 16
+		},
+		"duration": func(start, end time.Time) string {
+			return end.Sub(start).Round(time.Millisecond).String()
+		},
+		"format": func(t time.Time) string {
+			return t.Format(time.RFC3339Nano)
+		},
+	}).Parse(timelineHtmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if err = tmpl.Execute(buf, tl); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+const timelineHtmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.WorkflowId}} timeline</title>
+<style>
+body { font-family: -apple-system, "Helvetica Neue", Arial, sans-serif; font-size: 12px; margin: 16px; color: #222; }
+h1 { font-size: 16px; }
+.row { display: flex; align-items: center; height: 22px; border-bottom: 1px solid #f0f0f0; }
+.label { width: 280px; flex-shrink: 0; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; }
+.lane { color: #999; font-size: 10px; }
+.track { position: relative; flex-grow: 1; height: 16px; }
+.bar { position: absolute; height: 14px; top: 1px; min-width: 2px; border-radius: 2px; }
+.marker { position: absolute; top: -2px; width: 2px; height: 20px; }
+.workflow { background: #34495e; }
+.activity { background: #3498db; }
+.completed { background: #27ae60; }
+.failed { background: #e74c3c; }
+.timedOut { background: #e67e22; }
+.canceled { background: #95a5a6; }
+.timer { background: #9b59b6; }
+.wait { background: repeating-linear-gradient(45deg, #ddd, #ddd 4px, #f5f5f5 4px, #f5f5f5 8px); }
+.marker.signal, .marker.update { background: #8e44ad; }
+.marker.retry { background: #f1c40f; }
+.marker.failure { background: #c0392b; }
+</style>
+</head>
+<body>
+<h1>{{.WorkflowId}} {{.RunId}}</h1>
+<p>{{format .StartTime}} ~ {{format .EndTime}} ({{duration .StartTime .EndTime}})</p>
+{{range .Rows}}<div class="row">
+<div class="label" style="padding-left: {{indent .Depth}}px" title="{{.WorkflowId}} {{.Name}}">{{.Name}} <span class="lane">lane {{.Lane}}</span></div>
+<div class="track">
+{{range .Bars}}<div class="bar {{.Kind}} {{.Status}}" style="left: {{left .Start}}; width: {{width .Start .End}}" title="{{.Name}} {{.Status}} {{duration .Start .End}}{{if gt .Attempt 1}} attempt {{.Attempt}}{{end}} {{.Message}}"></div>
+{{end}}{{range .Markers}}<div class="marker {{.Kind}}" style="left: {{left .Time}}" title="{{.Kind}} {{.Name}} {{format .Time}} {{.Message}}"></div>
+{{end}}</div>
+</div>
+{{end}}</body>
+</html>
+`
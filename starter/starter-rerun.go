@@ -0,0 +1,179 @@
+package starter
+
+import (
+	"context"
+	"fmt"
+	"github.com/tianlin0/plat-lib/conv"
+	"github.com/tianlin0/temporal/activity"
+	"github.com/tianlin0/temporal/worker"
+	"github.com/tianlin0/temporal/workflow"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	temporalWorkflow "go.temporal.io/sdk/workflow"
+	"strings"
+)
+
+const (
+	RerunOfMemoKey = "rerunOf" //重新执行的流程在memo中记录原流程
+)
+
+// RerunOf 重新执行的流程对应的原流程
+type RerunOf struct {
+	WorkflowId string `json:"workflowId"`
+	RunId      string `json:"runId"`
+	ActivityId string `json:"activityId"` //从这个activity开始重新执行
+}
+
+// RerunFrom 从某个activity开始重新执行一个 DslWorkflow 流程
+// 在 activityId 第一次执行之前已经执行成功的activity，使用原流程中记录的参数和返回值，不再真正执行
+// 新流程的memo中 rerunOf 记录原流程
+func (su *startUp) RerunFrom(ctx context.Context, workflowId, runId, activityId string) (client.WorkflowRun, error) {
+	if activityId == "" {
+		return nil, fmt.Errorf("activityId is empty")
+	}
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	source := &clientHistorySource{temporalClient: temporalClient}
+	eventList, err := source.GetHistory(ctx, workflowId, runId)
+	if err != nil {
+		return nil, err
+	}
+	if len(eventList) == 0 {
+		return nil, fmt.Errorf("workflow %s history is empty", workflowId)
+	}
+
+	//原流程的输入：ActivityOptions 和 DslWorkflow
+	startAttr := eventList[0].GetWorkflowExecutionStartedEventAttributes()
+	payloads := startAttr.GetInput().GetPayloads()
+	if startAttr == nil || len(payloads) < 2 {
+		return nil, fmt.Errorf("workflow %s is not a DslWorkflow", workflowId)
+	}
+	dataConverter := su.getDataConverter()
+	var actOption *temporalWorkflow.ActivityOptions
+	if err = dataConverter.FromPayload(payloads[0], &actOption); err != nil {
+		return nil, err
+	}
+	dslWorkflow := new(workflow.DslWorkflow)
+	if err = dataConverter.FromPayload(payloads[1], dslWorkflow); err != nil {
+		return nil, fmt.Errorf("workflow %s is not a DslWorkflow: %s", workflowId, err.Error())
+	}
+
+	seeds, err := getRerunSeeds(ctx, dataConverter, source, eventList, workflowId, runId, activityId)
+	if err != nil {
+		return nil, err
+	}
+	if dslWorkflow.Seeds == nil {
+		dslWorkflow.Seeds = make(map[string]*workflow.StepSeed)
+	}
+	for id, seed := range seeds {
+		dslWorkflow.Seeds[id] = seed
+	}
+
+	if runId == "" {
+		runId = startAttr.GetOriginalExecutionRunId()
+	}
+	opts := &worker.SubmitOptions{
+		WorkflowId: workflowId,
+		IdPolicy:   worker.IdPolicyTemplate,
+		IdTemplate: "{{id}}-rerun-{{random}}",
+		Memo: map[string]interface{}{
+			RerunOfMemoKey: &RerunOf{
+				WorkflowId: workflowId,
+				RunId:      runId,
+				ActivityId: activityId,
+			},
+		},
+	}
+	return su.SubmitWithOptions(ctx, opts, actOption, dslWorkflow)
+}
+
+// GetRerunSeeds 根据历史事件获取从 activityId 重新执行时，已经执行成功不再执行的activity的参数和返回值
+// 包括 onExit: return 启动的子流程中的activity；离线时可以使用 FileHistorySource，payload使用默认的 DataConverter 解码
+func GetRerunSeeds(ctx context.Context, source HistorySource, workflowId, runId, activityId string) (map[string]*workflow.StepSeed, error) {
+	eventList, err := source.GetHistory(ctx, workflowId, runId)
+	if err != nil {
+		return nil, err
+	}
+	return getRerunSeeds(ctx, converter.GetDefaultDataConverter(), source, eventList, workflowId, runId, activityId)
+}
+
+// getRerunStepList 流程以及所有子流程中的activity，按执行顺序，子流程的记录在父流程之后
+func getRerunStepList(ctx context.Context, dataConverter converter.DataConverter, source HistorySource,
+	eventList []*historypb.HistoryEvent, workflowId, runId string) ([]*StepLog, error) {
+	stepList, childList, _ := parseStepLogs(dataConverter, eventList, "", workflowId, runId)
+	for _, child := range childList {
+		childEvents, err := source.GetHistory(ctx, child.GetWorkflowId(), child.GetRunId())
+		if err != nil {
+			return nil, err
+		}
+		childSteps, err := getRerunStepList(ctx, dataConverter, source, childEvents, child.GetWorkflowId(), child.GetRunId())
+		if err != nil {
+			return nil, err
+		}
+		stepList = append(stepList, childSteps...)
+	}
+	return stepList, nil
+}
+
+// getRerunSeeds 获取 activityId 第一次执行之前已经执行成功的activity的参数和返回值，eventList 为根流程的历史事件
+// 并行分支中在 activityId 开始调度之后才结束的activity会重新执行；
+// seed 按DSL中的id记录，activityId 之前有重复执行成功的activity时（如 poll、循环）无法对应到每一次执行，返回错误
+func getRerunSeeds(ctx context.Context, dataConverter converter.DataConverter, source HistorySource,
+	eventList []*historypb.HistoryEvent, workflowId, runId, activityId string) (map[string]*workflow.StepSeed, error) {
+	stepList, err := getRerunStepList(ctx, dataConverter, source, eventList, workflowId, runId)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *StepLog
+	for i, step := range stepList {
+		if step.StepId == activityId {
+			target = step
+			stepList = stepList[:i]
+			break
+		}
+	}
+	if target == nil {
+		return nil, fmt.Errorf("activity %s not found in workflow %s", activityId, workflowId)
+	}
+
+	seeds := make(map[string]*workflow.StepSeed)
+	for _, step := range stepList {
+		//流程结束时发送的通知不是DSL中的activity
+		if strings.HasSuffix(step.ActivityType, "/"+activity.NotifyWebhookTemplate) {
+			continue
+		}
+		if step.StepId == "" {
+			return nil, fmt.Errorf("workflow %s has no DSL activity id, can not rerun", step.WorkflowId)
+		}
+		if step.Status != StepStatusCompleted || step.CloseTime.After(target.ScheduledTime) {
+			continue
+		}
+		if _, ok := seeds[step.StepId]; ok {
+			return nil, fmt.Errorf("activity %s completed more than once before %s, can not rerun", step.StepId, activityId)
+		}
+		seed := &workflow.StepSeed{
+			Arguments: make(map[string]interface{}),
+			Responses: make(map[string]interface{}),
+		}
+		if len(step.Input) > 0 {
+			if err := conv.Unmarshal(step.Input[0], &seed.Arguments); err != nil {
+				return nil, err
+			}
+		}
+		if len(step.Output) > 0 {
+			if err := conv.Unmarshal(step.Output[0], &seed.Responses); err != nil {
+				return nil, err
+			}
+		}
+		seeds[step.StepId] = seed
+	}
+	return seeds, nil
+}
@@ -0,0 +1,342 @@
+package starter
+
+import (
+	"context"
+	"fmt"
+	"github.com/tianlin0/plat-lib/logs"
+	"github.com/tianlin0/temporal/workflow"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"time"
+)
+
+const (
+	ScheduleTaskQueueMemoKey = "taskQueue" //定时任务所属的队列，列表时只返回当前队列的定时任务
+)
+
+type (
+	// ScheduleOptions 定时执行某个DSL流程的配置
+	ScheduleOptions struct {
+		Id              string                 //定时任务的id
+		DslName         string                 //流程定义的名字，通过 Config.DslLoader 获取
+		Variables       map[string]interface{} //覆盖流程定义中的变量
+		CronExpressions []string               //如：0 2 * * *
+		Interval        time.Duration          //按固定间隔执行，和 CronExpressions 可以同时使用
+		Jitter          time.Duration          //每次执行随机延迟的最大时间，避免同时执行
+		// Overlap 上一次还在执行时的处理方式，默认为 SKIP
+		Overlap enums.ScheduleOverlapPolicy
+		Paused  bool
+		Note    string
+	}
+
+	// ScheduleRun 定时任务触发的一次执行，可以通过状态和日志接口查看
+	ScheduleRun struct {
+		ScheduleTime time.Time `json:"scheduleTime"`
+		ActualTime   time.Time `json:"actualTime"`
+		WorkflowId   string    `json:"workflowId"`
+		RunId        string    `json:"runId"`
+	}
+
+	// ScheduleInfo 定时任务的信息
+	ScheduleInfo struct {
+		Id              string         `json:"id"`
+		DslName         string         `json:"dslName"`
+		Paused          bool           `json:"paused"`
+		Note            string         `json:"note,omitempty"`
+		NextActionTimes []time.Time    `json:"nextActionTimes,omitempty"`
+		RecentRuns      []*ScheduleRun `json:"recentRuns,omitempty"`
+		RunningRuns     []*ScheduleRun `json:"runningRuns,omitempty"` //只有 DescribeSchedule 返回
+	}
+)
+
+// CreateSchedule 创建定时任务，定时执行 DslName 对应的流程
+func (su *startUp) CreateSchedule(ctx context.Context, opts *ScheduleOptions) (*ScheduleInfo, error) {
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return su.createSchedule(ctx, temporalClient.ScheduleClient(), opts)
+}
+
+func (su *startUp) createSchedule(ctx context.Context, scheduleClient client.ScheduleClient,
+	opts *ScheduleOptions) (*ScheduleInfo, error) {
+	action, err := su.getScheduleAction(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	handle, err := scheduleClient.Create(ctx, client.ScheduleOptions{
+		ID:      opts.Id,
+		Spec:    getScheduleSpec(opts),
+		Action:  action,
+		Overlap: opts.Overlap,
+		Paused:  opts.Paused,
+		Note:    opts.Note,
+		Memo:    su.getScheduleMemo(opts),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return su.describeSchedule(ctx, handle)
+}
+
+// UpdateSchedule 修改定时任务，会重新通过 DslLoader 获取流程定义
+func (su *startUp) UpdateSchedule(ctx context.Context, opts *ScheduleOptions) (*ScheduleInfo, error) {
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	action, err := su.getScheduleAction(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	handle := temporalClient.ScheduleClient().GetHandle(ctx, opts.Id)
+	err = handle.Update(ctx, client.ScheduleUpdateOptions{
+		DoUpdate: func(input client.ScheduleUpdateInput) (*client.ScheduleUpdate, error) {
+			schedule := input.Description.Schedule
+			spec := getScheduleSpec(opts)
+			schedule.Action = action
+			schedule.Spec = &spec
+			if schedule.Policy == nil {
+				schedule.Policy = new(client.SchedulePolicies)
+			}
+			schedule.Policy.Overlap = opts.Overlap
+			if schedule.State == nil {
+				schedule.State = new(client.ScheduleState)
+			}
+			schedule.State.Paused = opts.Paused
+			schedule.State.Note = opts.Note
+			return &client.ScheduleUpdate{Schedule: &schedule}, nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return su.describeSchedule(ctx, handle)
+}
+
+// PauseSchedule 暂停定时任务
+func (su *startUp) PauseSchedule(ctx context.Context, scheduleId string, note string) error {
+	handle, err := su.getScheduleHandle(ctx, scheduleId)
+	if err != nil {
+		return err
+	}
+	return handle.Pause(ctx, client.SchedulePauseOptions{Note: note})
+}
+
+// UnpauseSchedule 恢复暂停的定时任务
+func (su *startUp) UnpauseSchedule(ctx context.Context, scheduleId string, note string) error {
+	handle, err := su.getScheduleHandle(ctx, scheduleId)
+	if err != nil {
+		return err
+	}
+	return handle.Unpause(ctx, client.ScheduleUnpauseOptions{Note: note})
+}
+
+// TriggerSchedule 立即执行一次，overlap 为空时使用定时任务的配置
+func (su *startUp) TriggerSchedule(ctx context.Context, scheduleId string, overlap enums.ScheduleOverlapPolicy) error {
+	handle, err := su.getScheduleHandle(ctx, scheduleId)
+	if err != nil {
+		return err
+	}
+	return handle.Trigger(ctx, client.ScheduleTriggerOptions{Overlap: overlap})
+}
+
+// DeleteSchedule 删除定时任务，已经触发的流程不受影响
+func (su *startUp) DeleteSchedule(ctx context.Context, scheduleId string) error {
+	handle, err := su.getScheduleHandle(ctx, scheduleId)
+	if err != nil {
+		return err
+	}
+	return handle.Delete(ctx)
+}
+
+// DescribeSchedule 获取定时任务的详情，包括最近和正在执行的流程
+func (su *startUp) DescribeSchedule(ctx context.Context, scheduleId string) (*ScheduleInfo, error) {
+	handle, err := su.getScheduleHandle(ctx, scheduleId)
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return su.describeSchedule(ctx, handle)
+}
+
+// ListSchedules 获取当前队列的所有定时任务
+func (su *startUp) ListSchedules(ctx context.Context) ([]*ScheduleInfo, error) {
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return su.listSchedules(ctx, temporalClient.ScheduleClient())
+}
+
+// listSchedules 通过memo中的队列名过滤其他队列的定时任务
+func (su *startUp) listSchedules(ctx context.Context, scheduleClient client.ScheduleClient) ([]*ScheduleInfo, error) {
+	iter, err := scheduleClient.List(ctx, client.ScheduleListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	dataConverter := su.getDataConverter()
+	allList := make([]*ScheduleInfo, 0)
+	for iter.HasNext() {
+		entry, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var taskQueue, dslName string
+		if payload, ok := entry.Memo.GetFields()[ScheduleTaskQueueMemoKey]; ok {
+			_ = dataConverter.FromPayload(payload, &taskQueue)
+		}
+		if taskQueue != su.cfg.TaskQueueName {
+			continue
+		}
+		if payload, ok := entry.Memo.GetFields()[workflow.DslNameMemoKey]; ok {
+			_ = dataConverter.FromPayload(payload, &dslName)
+		}
+		allList = append(allList, &ScheduleInfo{
+			Id:              entry.ID,
+			DslName:         dslName,
+			Paused:          entry.Paused,
+			Note:            entry.Note,
+			NextActionTimes: entry.NextActionTimes,
+			RecentRuns:      getScheduleRuns(entry.RecentActions),
+		})
+	}
+	return allList, nil
+}
+
+func (su *startUp) getScheduleHandle(ctx context.Context, scheduleId string) (client.ScheduleHandle, error) {
+	if scheduleId == "" {
+		return nil, fmt.Errorf("scheduleId is empty")
+	}
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return temporalClient.ScheduleClient().GetHandle(ctx, scheduleId), nil
+}
+
+func (su *startUp) describeSchedule(ctx context.Context, handle client.ScheduleHandle) (*ScheduleInfo, error) {
+	desc, err := handle.Describe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	info := &ScheduleInfo{
+		Id:              handle.GetID(),
+		NextActionTimes: desc.Info.NextActionTimes,
+		RecentRuns:      getScheduleRuns(desc.Info.RecentActions),
+		RunningRuns:     make([]*ScheduleRun, 0),
+	}
+	if desc.Schedule.State != nil {
+		info.Paused = desc.Schedule.State.Paused
+		info.Note = desc.Schedule.State.Note
+	}
+	if payload, ok := desc.Memo.GetFields()[workflow.DslNameMemoKey]; ok {
+		if err = su.getDataConverter().FromPayload(payload, &info.DslName); err != nil {
+			logs.DefaultLogger().Error("DescribeSchedule decode memo error:", err)
+		}
+	}
+	for _, one := range desc.Info.RunningWorkflows {
+		info.RunningRuns = append(info.RunningRuns, &ScheduleRun{
+			WorkflowId: one.WorkflowID,
+			RunId:      one.FirstExecutionRunID,
+		})
+	}
+	return info, nil
+}
+
+// getScheduleAction 通过 DslLoader 获取流程定义，合并变量后作为定时任务执行的流程
+func (su *startUp) getScheduleAction(opts *ScheduleOptions) (*client.ScheduleWorkflowAction, error) {
+	cfg := su.cfg
+	if opts == nil || opts.Id == "" || opts.DslName == "" {
+		return nil, fmt.Errorf("schedule id or dslName is empty")
+	}
+	if cfg.TaskQueueName == "" || cfg.WorkerFlow == nil {
+		return nil, fmt.Errorf("cfg %s param error", cfg.TaskQueueName)
+	}
+	if cfg.DslLoader == nil {
+		return nil, fmt.Errorf("cfg %s DslLoader is null", cfg.TaskQueueName)
+	}
+
+	dslWorkflow, err := cfg.DslLoader(opts.DslName)
+	if err != nil {
+		return nil, err
+	}
+	if dslWorkflow == nil {
+		return nil, fmt.Errorf("dsl %s not found", opts.DslName)
+	}
+	if dslWorkflow.Name == "" {
+		dslWorkflow.Name = opts.DslName
+	}
+	if len(opts.Variables) > 0 {
+		if dslWorkflow.Variables == nil {
+			dslWorkflow.Variables = make(map[string]interface{})
+		}
+		for key, val := range opts.Variables {
+			dslWorkflow.Variables[key] = val
+		}
+	}
+	if err = dslWorkflow.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &client.ScheduleWorkflowAction{
+		ID:        fmt.Sprintf("%s/schedule/%s", cfg.TaskQueueName, opts.Id),
+		Workflow:  cfg.WorkerFlow,
+		Args:      []interface{}{cfg.ActivityOption, dslWorkflow},
+		TaskQueue: cfg.TaskQueueName,
+		Memo: map[string]interface{}{
+			workflow.DslNameMemoKey: dslWorkflow.Name,
+		},
+	}, nil
+}
+
+func (su *startUp) getScheduleMemo(opts *ScheduleOptions) map[string]interface{} {
+	return map[string]interface{}{
+		ScheduleTaskQueueMemoKey: su.cfg.TaskQueueName,
+		workflow.DslNameMemoKey:  opts.DslName,
+	}
+}
+
+func getScheduleSpec(opts *ScheduleOptions) client.ScheduleSpec {
+	spec := client.ScheduleSpec{
+		CronExpressions: opts.CronExpressions,
+		Jitter:          opts.Jitter,
+	}
+	if opts.Interval > 0 {
+		spec.Intervals = []client.ScheduleIntervalSpec{{Every: opts.Interval}}
+	}
+	return spec
+}
+
+func getScheduleRuns(actionList []client.ScheduleActionResult) []*ScheduleRun {
+	runList := make([]*ScheduleRun, 0, len(actionList))
+	for _, one := range actionList {
+		run := &ScheduleRun{
+			ScheduleTime: one.ScheduleTime,
+			ActualTime:   one.ActualTime,
+		}
+		if one.StartWorkflowResult != nil {
+			run.WorkflowId = one.StartWorkflowResult.WorkflowID
+			run.RunId = one.StartWorkflowResult.FirstExecutionRunID
+		}
+		runList = append(runList, run)
+	}
+	return runList
+}
@@ -0,0 +1,171 @@
+package starter
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/tianlin0/temporal/workflow"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+	temporalWorkflow "go.temporal.io/sdk/workflow"
+	"testing"
+	"time"
+)
+
+// newTestScheduleStartUp 定时任务使用的配置，DslLoader 每次返回新的流程定义
+func newTestScheduleStartUp() *startUp {
+	return New(&Config{
+		TaskQueueName:  "queue",
+		WorkerFlow:     "DslWorkflow",
+		ActivityOption: &temporalWorkflow.ActivityOptions{StartToCloseTimeout: time.Minute},
+		DslLoader: func(name string) (*workflow.DslWorkflow, error) {
+			return &workflow.DslWorkflow{
+				Variables: map[string]interface{}{"cdId": "default", "keep": "x"},
+				Root: workflow.Statement{
+					Activity: &workflow.ActivityInvocation{Id: "clean", Template: "clean"},
+				},
+			}, nil
+		},
+	})
+}
+
+// newTestMemo 使用配置的 DataConverter 编码memo
+func newTestMemo(t *testing.T, su *startUp, values map[string]interface{}) *commonpb.Memo {
+	memo := &commonpb.Memo{Fields: make(map[string]*commonpb.Payload)}
+	for key, val := range values {
+		payload, err := su.getDataConverter().ToPayload(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		memo.Fields[key] = payload
+	}
+	return memo
+}
+
+func TestCreateSchedule(t *testing.T) {
+	su := newTestScheduleStartUp()
+	t0 := time.Date(2024, 1, 1, 2, 0, 0, 0, time.UTC)
+
+	handle := &mocks.ScheduleHandle{}
+	handle.On("GetID").Return("nightly-clean")
+	desc := &client.ScheduleDescription{
+		Schedule: client.Schedule{State: &client.ScheduleState{Note: "nightly"}},
+		Memo: newTestMemo(t, su, map[string]interface{}{
+			ScheduleTaskQueueMemoKey: "queue",
+			workflow.DslNameMemoKey:  "clean",
+		}),
+	}
+	desc.Info.NextActionTimes = []time.Time{t0.Add(24 * time.Hour)}
+	desc.Info.RecentActions = []client.ScheduleActionResult{{
+		ScheduleTime: t0,
+		ActualTime:   t0.Add(time.Second),
+		StartWorkflowResult: &client.ScheduleWorkflowExecution{
+			WorkflowID:          "queue/schedule/nightly-clean-2024-01-01T02:00:00Z",
+			FirstExecutionRunID: "run-1",
+		},
+	}}
+	handle.On("Describe", mock.Anything).Return(desc, nil)
+
+	var created client.ScheduleOptions
+	scheduleClient := &mocks.ScheduleClient{}
+	scheduleClient.On("Create", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		created = args.Get(1).(client.ScheduleOptions)
+	}).Return(handle, nil)
+
+	info, err := su.createSchedule(context.Background(), scheduleClient, &ScheduleOptions{
+		Id:              "nightly-clean",
+		DslName:         "clean",
+		Variables:       map[string]interface{}{"cdId": "cd-1"},
+		CronExpressions: []string{"0 2 * * *"},
+		Interval:        time.Hour,
+		Jitter:          time.Minute,
+		Overlap:         enums.SCHEDULE_OVERLAP_POLICY_SKIP,
+		Note:            "nightly",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if created.ID != "nightly-clean" || created.Overlap != enums.SCHEDULE_OVERLAP_POLICY_SKIP ||
+		len(created.Spec.CronExpressions) != 1 || len(created.Spec.Intervals) != 1 ||
+		created.Spec.Intervals[0].Every != time.Hour || created.Spec.Jitter != time.Minute {
+		t.Fatalf("unexpected schedule options: %+v", created)
+	}
+	if created.Memo[ScheduleTaskQueueMemoKey] != "queue" || created.Memo[workflow.DslNameMemoKey] != "clean" {
+		t.Fatalf("unexpected schedule memo: %+v", created.Memo)
+	}
+	action, ok := created.Action.(*client.ScheduleWorkflowAction)
+	if !ok || action.ID != "queue/schedule/nightly-clean" || action.TaskQueue != "queue" ||
+		action.Memo[workflow.DslNameMemoKey] != "clean" {
+		t.Fatalf("unexpected schedule action: %+v", created.Action)
+	}
+
+	//定时任务保存的是创建时的流程定义，编码后再解码与worker收到的一致
+	payloads, err := su.getDataConverter().ToPayloads(action.Args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actOption temporalWorkflow.ActivityOptions
+	var snapshot workflow.DslWorkflow
+	if err = su.getDataConverter().FromPayloads(payloads, &actOption, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Name != "clean" || snapshot.Variables["cdId"] != "cd-1" || snapshot.Variables["keep"] != "x" ||
+		snapshot.Root.Activity == nil || snapshot.Root.Activity.Id != "clean" {
+		t.Fatalf("unexpected dsl snapshot: %+v", snapshot)
+	}
+	if actOption.StartToCloseTimeout != time.Minute {
+		t.Fatalf("unexpected activity options: %+v", actOption)
+	}
+
+	if info.Id != "nightly-clean" || info.DslName != "clean" || info.Note != "nightly" || len(info.NextActionTimes) != 1 ||
+		len(info.RecentRuns) != 1 || info.RecentRuns[0].RunId != "run-1" || len(info.RunningRuns) != 0 {
+		t.Fatalf("unexpected schedule info: %+v", info)
+	}
+
+	if _, err = su.createSchedule(context.Background(), scheduleClient, &ScheduleOptions{Id: "no-dsl"}); err == nil {
+		t.Fatal("expected error for empty dslName")
+	}
+}
+
+func TestListSchedules(t *testing.T) {
+	su := newTestScheduleStartUp()
+	entries := []*client.ScheduleListEntry{
+		{ID: "nightly-clean", Note: "nightly", Memo: newTestMemo(t, su, map[string]interface{}{
+			ScheduleTaskQueueMemoKey: "queue",
+			workflow.DslNameMemoKey:  "clean",
+		})},
+		{ID: "other-queue", Memo: newTestMemo(t, su, map[string]interface{}{
+			ScheduleTaskQueueMemoKey: "other",
+			workflow.DslNameMemoKey:  "clean",
+		})},
+		{ID: "no-memo"},
+		{ID: "paused-recycle", Paused: true, Memo: newTestMemo(t, su, map[string]interface{}{
+			ScheduleTaskQueueMemoKey: "queue",
+			workflow.DslNameMemoKey:  "recycle",
+		})},
+	}
+	iter := &mocks.ScheduleListIterator{}
+	for _, entry := range entries {
+		iter.On("HasNext").Return(true).Once()
+		iter.On("Next").Return(entry, nil).Once()
+	}
+	iter.On("HasNext").Return(false)
+	scheduleClient := &mocks.ScheduleClient{}
+	scheduleClient.On("List", mock.Anything, mock.Anything).Return(iter, nil)
+
+	list, err := su.listSchedules(context.Background(), scheduleClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 schedules of queue, got %d", len(list))
+	}
+	if list[0].Id != "nightly-clean" || list[0].DslName != "clean" || list[0].Note != "nightly" {
+		t.Fatalf("unexpected schedule: %+v", list[0])
+	}
+	if list[1].Id != "paused-recycle" || list[1].DslName != "recycle" || !list[1].Paused {
+		t.Fatalf("unexpected schedule: %+v", list[1])
+	}
+}
@@ -0,0 +1,97 @@
+package starter
+
+import (
+	"context"
+	"github.com/tianlin0/plat-lib/logs"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/client"
+	"time"
+)
+
+// WorkflowStatusNode 流程及其所有子流程的状态树
+// 子流程从历史事件 ChildWorkflowExecutionStarted 中获取，包括已经结束的子流程，如 onExit: return 启动的后续流程
+type WorkflowStatusNode struct {
+	WorkflowId     string                        `json:"workflowId"`
+	RunId          string                        `json:"runId"`
+	WorkflowType   string                        `json:"workflowType"`
+	Status         enums.WorkflowExecutionStatus `json:"status"`
+	StartTime      time.Time                     `json:"startTime"`
+	CloseTime      time.Time                     `json:"closeTime,omitempty"` //运行中的流程为空
+	FailureMessage string                        `json:"failureMessage,omitempty"`
+	Error          string                        `json:"error,omitempty"` //获取该流程的状态或者历史失败时的错误，不影响其他节点
+	Children       []*WorkflowStatusNode         `json:"children,omitempty"`
+}
+
+// GetAllWorkflowStatus 获取流程以及所有层级子流程的状态
+// 根流程获取失败时返回错误，子流程获取失败时错误记录在对应节点的 Error 中
+func (su *startUp) GetAllWorkflowStatus(workflowId, runId string) (*WorkflowStatusNode, error) {
+	temporalClient, err := su.getTemporalClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	root := &WorkflowStatusNode{
+		WorkflowId: workflowId,
+		RunId:      runId,
+	}
+	if err = fillWorkflowStatusNode(ctx, temporalClient, root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// fillWorkflowStatusNode 查询流程的状态，并遍历历史事件递归获取子流程
+func fillWorkflowStatusNode(ctx context.Context, temporalClient client.Client, node *WorkflowStatusNode) error {
+	descResp, err := temporalClient.DescribeWorkflowExecution(ctx, node.WorkflowId, node.RunId)
+	if err != nil {
+		return err
+	}
+	info := descResp.GetWorkflowExecutionInfo()
+	node.RunId = info.GetExecution().GetRunId()
+	node.WorkflowType = info.GetType().GetName()
+	node.Status = info.GetStatus()
+	if info.GetStartTime() != nil {
+		node.StartTime = info.GetStartTime().AsTime()
+	}
+	if info.GetCloseTime() != nil {
+		node.CloseTime = info.GetCloseTime().AsTime()
+	}
+
+	node.Children = make([]*WorkflowStatusNode, 0)
+	iter := temporalClient.GetWorkflowHistory(ctx, node.WorkflowId, node.RunId, false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			node.Error = err.Error()
+			break
+		}
+		if attr := event.GetChildWorkflowExecutionStartedEventAttributes(); attr != nil {
+			node.Children = append(node.Children, &WorkflowStatusNode{
+				WorkflowId:   attr.GetWorkflowExecution().GetWorkflowId(),
+				RunId:        attr.GetWorkflowExecution().GetRunId(),
+				WorkflowType: attr.GetWorkflowType().GetName(),
+			})
+			continue
+		}
+		if attr := event.GetWorkflowExecutionFailedEventAttributes(); attr != nil {
+			node.FailureMessage = attr.GetFailure().GetMessage()
+			continue
+		}
+		if attr := event.GetWorkflowExecutionTerminatedEventAttributes(); attr != nil {
+			node.FailureMessage = attr.GetReason()
+			continue
+		}
+		if attr := event.GetWorkflowExecutionTimedOutEventAttributes(); attr != nil {
+			node.FailureMessage = "workflow execution timed out"
+		}
+	}
+
+	for _, child := range node.Children {
+		if err = fillWorkflowStatusNode(ctx, temporalClient, child); err != nil {
+			logs.DefaultLogger().Error("GetAllWorkflowStatus child error:", child.WorkflowId, err)
+			child.Error = err.Error()
+		}
+	}
+	return nil
+}
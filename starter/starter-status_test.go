@@ -0,0 +1,154 @@
+package starter
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	historypb "go.temporal.io/api/history/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"testing"
+	"time"
+)
+
+// testWorkflowRun 测试用的一次流程执行，包括状态和历史事件
+type testWorkflowRun struct {
+	workflowId string
+	runId      string
+	status     enums.WorkflowExecutionStatus
+	events     []*historypb.HistoryEvent
+	err        error //获取状态和历史时返回的错误
+}
+
+// testHistoryIterator 按顺序返回历史事件
+type testHistoryIterator struct {
+	events []*historypb.HistoryEvent
+	err    error
+}
+
+func (it *testHistoryIterator) HasNext() bool {
+	return it.err != nil || len(it.events) > 0
+}
+
+func (it *testHistoryIterator) Next() (*historypb.HistoryEvent, error) {
+	if it.err != nil {
+		return nil, it.err
+	}
+	event := it.events[0]
+	it.events = it.events[1:]
+	return event, nil
+}
+
+// newTestHistoryClient 返回固定状态和历史事件的temporal连接
+func newTestHistoryClient(runs ...*testWorkflowRun) *mocks.Client {
+	c := &mocks.Client{}
+	for _, run := range runs {
+		run := run
+		var descResp *workflowservice.DescribeWorkflowExecutionResponse
+		if run.err == nil {
+			descResp = &workflowservice.DescribeWorkflowExecutionResponse{
+				WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+					Execution: &commonpb.WorkflowExecution{WorkflowId: run.workflowId, RunId: run.runId},
+					Type:      &commonpb.WorkflowType{Name: "DslWorkflow"},
+					Status:    run.status,
+				},
+			}
+		}
+		c.On("DescribeWorkflowExecution", mock.Anything, run.workflowId, run.runId).Return(descResp, run.err)
+		c.On("GetWorkflowHistory", mock.Anything, run.workflowId, run.runId, false,
+			enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).
+			Return(func(context.Context, string, string, bool, enums.HistoryEventFilterType) client.HistoryEventIterator {
+				return &testHistoryIterator{events: run.events, err: run.err}
+			})
+	}
+	return c
+}
+
+// newTestEvent 生成测试用的历史事件
+func newTestEvent(id int64, eventType enums.EventType, attr interface{}) *historypb.HistoryEvent {
+	event := &historypb.HistoryEvent{
+		EventId:   id,
+		EventTime: timestamppb.New(time.Date(2024, 1, 1, 0, 0, int(id), 0, time.UTC)),
+		EventType: eventType,
+	}
+	switch a := attr.(type) {
+	case *historypb.ActivityTaskScheduledEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_ActivityTaskScheduledEventAttributes{ActivityTaskScheduledEventAttributes: a}
+	case *historypb.ActivityTaskCompletedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_ActivityTaskCompletedEventAttributes{ActivityTaskCompletedEventAttributes: a}
+	case *historypb.ActivityTaskFailedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_ActivityTaskFailedEventAttributes{ActivityTaskFailedEventAttributes: a}
+	case *historypb.ChildWorkflowExecutionStartedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_ChildWorkflowExecutionStartedEventAttributes{ChildWorkflowExecutionStartedEventAttributes: a}
+	case *historypb.WorkflowExecutionFailedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionFailedEventAttributes{WorkflowExecutionFailedEventAttributes: a}
+	}
+	return event
+}
+
+// newTestChildStarted 启动子流程的历史事件
+func newTestChildStarted(id int64, workflowId, runId string) *historypb.HistoryEvent {
+	return newTestEvent(id, enums.EVENT_TYPE_CHILD_WORKFLOW_EXECUTION_STARTED,
+		&historypb.ChildWorkflowExecutionStartedEventAttributes{
+			WorkflowExecution: &commonpb.WorkflowExecution{WorkflowId: workflowId, RunId: runId},
+			WorkflowType:      &commonpb.WorkflowType{Name: "DslWorkflow"},
+		})
+}
+
+func TestFillWorkflowStatusNode(t *testing.T) {
+	c := newTestHistoryClient(
+		&testWorkflowRun{workflowId: "root", runId: "r1", status: enums.WORKFLOW_EXECUTION_STATUS_COMPLETED,
+			events: []*historypb.HistoryEvent{
+				newTestChildStarted(5, "deploy-child", "c1"),
+				newTestChildStarted(9, "deploy-child", "c2"),
+				newTestChildStarted(13, "lost-child", "c3"),
+				newTestEvent(20, enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED, nil),
+			}},
+		&testWorkflowRun{workflowId: "deploy-child", runId: "c1", status: enums.WORKFLOW_EXECUTION_STATUS_FAILED,
+			events: []*historypb.HistoryEvent{
+				newTestChildStarted(5, "notify-child", "g1"),
+				newTestEvent(8, enums.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED,
+					&historypb.WorkflowExecutionFailedEventAttributes{Failure: &failurepb.Failure{Message: "deploy failed"}}),
+			}},
+		&testWorkflowRun{workflowId: "deploy-child", runId: "c2", status: enums.WORKFLOW_EXECUTION_STATUS_COMPLETED},
+		&testWorkflowRun{workflowId: "notify-child", runId: "g1", status: enums.WORKFLOW_EXECUTION_STATUS_COMPLETED},
+		&testWorkflowRun{workflowId: "lost-child", runId: "c3", err: fmt.Errorf("workflow not found")},
+	)
+
+	root := &WorkflowStatusNode{WorkflowId: "root", RunId: "r1"}
+	if err := fillWorkflowStatusNode(context.Background(), c, root); err != nil {
+		t.Fatal(err)
+	}
+	if root.Status != enums.WORKFLOW_EXECUTION_STATUS_COMPLETED || len(root.Children) != 3 {
+		t.Fatalf("unexpected root: %+v", root)
+	}
+	// 同一个子流程id执行了两次，按启动顺序各是一个节点
+	first, second, lost := root.Children[0], root.Children[1], root.Children[2]
+	if first.WorkflowId != "deploy-child" || first.RunId != "c1" || second.WorkflowId != "deploy-child" || second.RunId != "c2" {
+		t.Fatalf("unexpected children: %+v %+v", first, second)
+	}
+	if first.Status != enums.WORKFLOW_EXECUTION_STATUS_FAILED || first.FailureMessage != "deploy failed" || first.Error != "" {
+		t.Fatalf("unexpected failed child: %+v", first)
+	}
+	if len(first.Children) != 1 || first.Children[0].WorkflowId != "notify-child" ||
+		first.Children[0].Status != enums.WORKFLOW_EXECUTION_STATUS_COMPLETED {
+		t.Fatalf("unexpected grandchild: %+v", first.Children)
+	}
+	if second.Status != enums.WORKFLOW_EXECUTION_STATUS_COMPLETED || len(second.Children) != 0 {
+		t.Fatalf("unexpected second run: %+v", second)
+	}
+	if lost.Error != "workflow not found" {
+		t.Fatalf("child error not recorded: %+v", lost)
+	}
+
+	// 根流程获取失败时返回错误
+	if err := fillWorkflowStatusNode(context.Background(), c, &WorkflowStatusNode{WorkflowId: "lost-child", RunId: "c3"}); err == nil {
+		t.Fatal("expected root error")
+	}
+}
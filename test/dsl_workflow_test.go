@@ -0,0 +1,862 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+	"github.com/tianlin0/plat-lib/cond"
+	"github.com/tianlin0/plat-lib/conv"
+	"github.com/tianlin0/temporal/activity"
+	"github.com/tianlin0/temporal/workflow"
+	temporalActivity "go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	temporalWorkflow "go.temporal.io/sdk/workflow"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTaskQueue = "default-test-taskqueue"
+
+var registerTestActivityOnce sync.Map
+
+// newDslTestEnv 新建一个测试环境，并注册测试用的activity
+func newDslTestEnv(t *testing.T, actList map[string]activity.TemplateMethod) *testsuite.TestWorkflowEnvironment {
+	return newDslTestEnvWithSuite(t, &testsuite.WorkflowTestSuite{}, actList)
+}
+
+// newDslTestEnvWithSuite 使用指定的测试套件新建测试环境，比如需要设置指标的时候
+func newDslTestEnvWithSuite(t *testing.T, s *testsuite.WorkflowTestSuite, actList map[string]activity.TemplateMethod) *testsuite.TestWorkflowEnvironment {
+	env := s.NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(workflow.New().GetDslWorkflow().DslWorkflow,
+		temporalWorkflow.RegisterOptions{Name: "DslWorkflow"})
+
+	ac := activity.New()
+	for name, method := range actList {
+		env.RegisterActivityWithOptions(method, temporalActivity.RegisterOptions{
+			Name: ac.GetActivityName(testTaskQueue, name),
+		})
+		if _, loaded := registerTestActivityOnce.LoadOrStore(name, true); !loaded {
+			if err := ac.SetActivityMethodName(testTaskQueue, name, method); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return env
+}
+
+func loadDslFromYaml(t *testing.T, content string) *workflow.DslWorkflow {
+	var dsl workflow.DslWorkflow
+	if err := yaml.Unmarshal([]byte(content), &dsl); err != nil {
+		t.Fatal(err)
+	}
+	return &dsl
+}
+
+func TestDslParallelIsolatedBindings(t *testing.T) {
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"parallel-echo": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"value": param["value"]}, nil
+		},
+	})
+
+	dsl := loadDslFromYaml(t, `
+root:
+  parallel:
+    - activity:
+        id: branch-a
+        template: parallel-echo
+        arguments:
+          value: a
+    - activity:
+        id: branch-b
+        template: parallel-echo
+        arguments:
+          value: b
+responses:
+  a: "{{branch-a.responses.value}}"
+  b: "{{branch-b.responses.value}}"
+`)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	ret := make(map[string]interface{})
+	if err := env.GetWorkflowResult(&ret); err != nil {
+		t.Fatal(err)
+	}
+	if conv.String(ret["a"]) != "a" || conv.String(ret["b"]) != "b" {
+		t.Fatalf("unexpected responses: %s", conv.String(ret))
+	}
+}
+
+func TestDslParallelSiblingReference(t *testing.T) {
+	dsl := loadDslFromYaml(t, `
+root:
+  parallel:
+    - activity:
+        id: branch-a
+        template: parallel-echo
+    - activity:
+        id: branch-b
+        template: parallel-echo
+        arguments:
+          value: "{{branch-a.responses.value}}"
+`)
+
+	err := dsl.Validate()
+	if err == nil || !strings.Contains(err.Error(), "branch-a") {
+		t.Fatalf("expected sibling reference error, got %v", err)
+	}
+}
+
+func TestDslPollUntilReady(t *testing.T) {
+	var calls int
+	activityIds := make([]string, 0)
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"poll-status": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			calls++
+			activityIds = append(activityIds, temporalActivity.GetInfo(ctx).ActivityID)
+			status := "pending"
+			if calls >= 3 {
+				status = "ready"
+			}
+			return map[string]interface{}{"status": status}, nil
+		},
+	})
+
+	dsl := loadDslFromYaml(t, `
+root:
+  poll:
+    activity:
+      id: poll-status
+      template: poll-status
+    until: "'{{poll-status.responses.status}}' == 'ready'"
+    interval: 5s
+    backoffcoefficient: 2
+    maxattempts: 5
+responses:
+  status: "{{poll-status.responses.status}}"
+`)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	ret := make(map[string]interface{})
+	if err := env.GetWorkflowResult(&ret); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || conv.String(ret["status"]) != "ready" {
+		t.Fatalf("unexpected poll result: calls=%d, %s", calls, conv.String(ret))
+	}
+	if strings.Join(activityIds, ",") != "poll-status,poll-status#2,poll-status#3" {
+		t.Fatalf("unexpected activity ids: %v", activityIds)
+	}
+}
+
+func TestDslApproval(t *testing.T) {
+	dslContent := `
+root:
+  approval:
+    id: delete-approval
+    assignee: ["alice"]
+    timeout: 1h
+    onreject:
+      - activity:
+          id: notify-reject
+          template: approval-echo
+          arguments:
+            value: rejected
+  sequence:
+    - activity:
+        id: do-delete
+        template: approval-echo
+        arguments:
+          value: deleted
+responses:
+  status: "{{delete-approval.responses.status}}"
+  approver: "{{delete-approval.responses.approver}}"
+  deleted: "{{do-delete.responses.value}}"
+`
+	actList := map[string]activity.TemplateMethod{
+		"approval-echo": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"value": param["value"]}, nil
+		},
+	}
+
+	//审批通过
+	env := newDslTestEnv(t, actList)
+	env.RegisterDelayedCallback(func() {
+		env.SignalWorkflow(workflow.ApprovalSignalName, &workflow.ApprovalDecision{
+			Id:       "delete-approval",
+			Approved: true,
+			Approver: "alice",
+		})
+	}, time.Minute)
+	env.ExecuteWorkflow("DslWorkflow", nil, loadDslFromYaml(t, dslContent))
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	ret := make(map[string]interface{})
+	_ = env.GetWorkflowResult(&ret)
+	if conv.String(ret["status"]) != workflow.ApprovalStatusApproved || conv.String(ret["approver"]) != "alice" ||
+		conv.String(ret["deleted"]) != "deleted" {
+		t.Fatalf("unexpected approval result: %s", conv.String(ret))
+	}
+
+	//超时自动拒绝
+	env = newDslTestEnv(t, actList)
+	env.ExecuteWorkflow("DslWorkflow", nil, loadDslFromYaml(t, dslContent))
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	ret = make(map[string]interface{})
+	_ = env.GetWorkflowResult(&ret)
+	if conv.String(ret["status"]) != workflow.ApprovalStatusExpired || conv.String(ret["deleted"]) == "deleted" {
+		t.Fatalf("unexpected approval result: %s", conv.String(ret))
+	}
+}
+
+// testUpdateCallback 记录update的处理结果
+type testUpdateCallback struct {
+	err error
+}
+
+func (c *testUpdateCallback) Accept() {}
+
+func (c *testUpdateCallback) Reject(err error) {
+	c.err = err
+}
+
+func (c *testUpdateCallback) Complete(success interface{}, err error) {
+	c.err = err
+}
+
+func TestDslWaitOnFailure(t *testing.T) {
+	var calls int
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"always-fail": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			calls++
+			return nil, fmt.Errorf("backend is down")
+		},
+	})
+
+	dsl := loadDslFromYaml(t, `
+waitonfailure: true
+root:
+  activity:
+    id: flaky-step
+    template: always-fail
+responses:
+  value: "{{flaky-step.responses.value}}"
+`)
+
+	retryCallback := &testUpdateCallback{}
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(workflow.RetryStepUpdateName, "retry-1", retryCallback,
+			&workflow.StepDecision{ActivityId: "flaky-step"})
+	}, time.Minute)
+	skipCallback := &testUpdateCallback{}
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(workflow.SkipStepUpdateName, "skip-1", skipCallback, &workflow.StepDecision{
+			ActivityId: "flaky-step",
+			Responses:  map[string]interface{}{"value": "fake"},
+		})
+	}, 2*time.Minute)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if retryCallback.err != nil || skipCallback.err != nil {
+		t.Fatal(retryCallback.err, skipCallback.err)
+	}
+	ret := make(map[string]interface{})
+	_ = env.GetWorkflowResult(&ret)
+	if calls != 2 || conv.String(ret["value"]) != "fake" {
+		t.Fatalf("unexpected result: calls=%d, %s", calls, conv.String(ret))
+	}
+}
+
+// queryControlState 查询流程的暂停、失败等待等状态
+func queryControlState(t *testing.T, env *testsuite.TestWorkflowEnvironment) *workflow.ControlState {
+	val, err := env.QueryWorkflow(workflow.ControlStateQueryName)
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	state := new(workflow.ControlState)
+	if err = val.Get(state); err != nil {
+		t.Error(err)
+	}
+	return state
+}
+
+// TestDslPauseSkipResume 暂停后activity不执行，跳过还未执行的activity，恢复后使用设置的返回值
+func TestDslPauseSkipResume(t *testing.T) {
+	var calls []string
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"control-echo": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			calls = append(calls, temporalActivity.GetInfo(ctx).ActivityID)
+			return map[string]interface{}{"value": "real"}, nil
+		},
+	})
+
+	dsl := loadDslFromYaml(t, `
+root:
+  sequence:
+    - activity:
+        id: check-cd
+        template: control-echo
+    - activity:
+        id: delete-cd
+        template: control-echo
+responses:
+  checked: "{{check-cd.responses.value}}"
+  deleted: "{{delete-cd.responses.value}}"
+`)
+
+	pauseCallback := &testUpdateCallback{}
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(workflow.PauseUpdateName, "pause-1", pauseCallback, "alice")
+	}, 0)
+	skipCallback := &testUpdateCallback{}
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(workflow.SkipStepUpdateName, "skip-1", skipCallback, &workflow.StepDecision{
+			ActivityId: "delete-cd",
+			Responses:  map[string]interface{}{"value": "fake"},
+			Operator:   "alice",
+		})
+	}, time.Minute)
+	var pausedState *workflow.ControlState
+	var pausedCalls int
+	env.RegisterDelayedCallback(func() {
+		pausedState = queryControlState(t, env)
+		pausedCalls = len(calls)
+		env.UpdateWorkflow(workflow.ResumeUpdateName, "resume-1", &testUpdateCallback{}, "alice")
+	}, 2*time.Minute)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if pauseCallback.err != nil || skipCallback.err != nil {
+		t.Fatal(pauseCallback.err, skipCallback.err)
+	}
+	if pausedState == nil || !pausedState.Paused || conv.String(pausedState.SkipSteps) != conv.String([]string{"delete-cd"}) {
+		t.Fatalf("unexpected paused state: %s", conv.String(pausedState))
+	}
+	if pausedCalls != 0 {
+		t.Fatalf("activity executed while paused: %d", pausedCalls)
+	}
+	ret := make(map[string]interface{})
+	_ = env.GetWorkflowResult(&ret)
+	if conv.String(calls) != conv.String([]string{"check-cd"}) ||
+		conv.String(ret["checked"]) != "real" || conv.String(ret["deleted"]) != "fake" {
+		t.Fatalf("unexpected result: calls=%v, %s", calls, conv.String(ret))
+	}
+}
+
+// TestDslRetryFailedStep 失败等待处理时重试，第二次执行成功
+func TestDslRetryFailedStep(t *testing.T) {
+	var calls int
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"control-fail-once": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			calls++
+			if calls == 1 {
+				return nil, fmt.Errorf("backend is down")
+			}
+			return map[string]interface{}{"value": "real"}, nil
+		},
+	})
+
+	dsl := loadDslFromYaml(t, `
+waitonfailure: true
+root:
+  activity:
+    id: flaky-step
+    template: control-fail-once
+responses:
+  value: "{{flaky-step.responses.value}}"
+`)
+
+	var failedState *workflow.ControlState
+	retryCallback := &testUpdateCallback{}
+	env.RegisterDelayedCallback(func() {
+		failedState = queryControlState(t, env)
+		env.UpdateWorkflow(workflow.RetryStepUpdateName, "retry-1", retryCallback,
+			&workflow.StepDecision{ActivityId: "flaky-step", Operator: "alice"})
+	}, time.Minute)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if retryCallback.err != nil {
+		t.Fatal(retryCallback.err)
+	}
+	if failedState == nil || !strings.Contains(failedState.FailedSteps["flaky-step"], "backend is down") {
+		t.Fatalf("unexpected failed state: %s", conv.String(failedState))
+	}
+	ret := make(map[string]interface{})
+	_ = env.GetWorkflowResult(&ret)
+	if calls != 2 || conv.String(ret["value"]) != "real" {
+		t.Fatalf("unexpected result: calls=%d, %s", calls, conv.String(ret))
+	}
+}
+
+// TestDslStepDecisionRejected 跳过、重试不存在的activity，或者已经执行完成的activity时拒绝
+func TestDslStepDecisionRejected(t *testing.T) {
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"control-ok": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"value": "real"}, nil
+		},
+		"control-fail": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return nil, fmt.Errorf("backend is down")
+		},
+	})
+
+	dsl := loadDslFromYaml(t, `
+waitonfailure: true
+root:
+  sequence:
+    - activity:
+        id: check-cd
+        template: control-ok
+    - activity:
+        id: delete-cd
+        template: control-fail
+`)
+
+	rejected := map[string]*testUpdateCallback{
+		"skip-unknown":    {},
+		"retry-unknown":   {},
+		"skip-completed":  {},
+		"retry-completed": {},
+		"skip-empty":      {},
+	}
+	skipCallback := &testUpdateCallback{}
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(workflow.SkipStepUpdateName, "skip-unknown", rejected["skip-unknown"],
+			&workflow.StepDecision{ActivityId: "not-exist"})
+		env.UpdateWorkflow(workflow.RetryStepUpdateName, "retry-unknown", rejected["retry-unknown"],
+			&workflow.StepDecision{ActivityId: "not-exist"})
+		env.UpdateWorkflow(workflow.SkipStepUpdateName, "skip-completed", rejected["skip-completed"],
+			&workflow.StepDecision{ActivityId: "check-cd"})
+		env.UpdateWorkflow(workflow.RetryStepUpdateName, "retry-completed", rejected["retry-completed"],
+			&workflow.StepDecision{ActivityId: "check-cd"})
+		env.UpdateWorkflow(workflow.SkipStepUpdateName, "skip-empty", rejected["skip-empty"],
+			&workflow.StepDecision{})
+	}, time.Minute)
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(workflow.SkipStepUpdateName, "skip-1", skipCallback,
+			&workflow.StepDecision{ActivityId: "delete-cd"})
+	}, 2*time.Minute)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	for name, callback := range rejected {
+		if callback.err == nil {
+			t.Fatalf("%s should be rejected", name)
+		}
+	}
+	if skipCallback.err != nil {
+		t.Fatal(skipCallback.err)
+	}
+}
+
+func TestDslPatchVariables(t *testing.T) {
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"patch-echo": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"cdId": param["cdId"]}, nil
+		},
+	})
+
+	dsl := loadDslFromYaml(t, `
+variables:
+  cdId: wrong-id
+root:
+  sequence:
+    - activity:
+        id: check-cd
+        template: patch-echo
+    - approval:
+        id: wait-operator
+      activity:
+        id: delete-cd
+        template: patch-echo
+responses:
+  checked: "{{check-cd.responses.cdId}}"
+  deleted: "{{delete-cd.responses.cdId}}"
+`)
+
+	rejectCallback := &testUpdateCallback{}
+	patchCallback := &testUpdateCallback{}
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(workflow.PatchVariablesUpdateName, "patch-1", rejectCallback, &workflow.VariablesPatch{
+			Arguments: map[string]map[string]interface{}{"check-cd": {"cdId": "x"}},
+		})
+		env.UpdateWorkflow(workflow.PatchVariablesUpdateName, "patch-2", patchCallback, &workflow.VariablesPatch{
+			Variables: map[string]interface{}{"cdId": "right-id"},
+			Operator:  "alice",
+		})
+		env.SignalWorkflow(workflow.ApprovalSignalName, &workflow.ApprovalDecision{Id: "wait-operator", Approved: true})
+	}, time.Minute)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if rejectCallback.err == nil || patchCallback.err != nil {
+		t.Fatalf("unexpected patch result: %v, %v", rejectCallback.err, patchCallback.err)
+	}
+	ret := make(map[string]interface{})
+	_ = env.GetWorkflowResult(&ret)
+	if conv.String(ret["checked"]) != "wrong-id" || conv.String(ret["deleted"]) != "right-id" {
+		t.Fatalf("unexpected result: %s", conv.String(ret))
+	}
+}
+
+// TestDslPatchVariablesAfterAllStarted 所有activity都已经开始执行时，修改变量不会生效，需要拒绝
+func TestDslPatchVariablesAfterAllStarted(t *testing.T) {
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"patch-echo": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"cdId": param["cdId"]}, nil
+		},
+		"patch-fail": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return nil, fmt.Errorf("backend is down")
+		},
+	})
+
+	dsl := loadDslFromYaml(t, `
+waitonfailure: true
+variables:
+  cdId: cd-1
+root:
+  sequence:
+    - activity:
+        id: check-cd
+        template: patch-echo
+    - activity:
+        id: delete-cd
+        template: patch-fail
+responses:
+  checked: "{{check-cd.responses.cdId}}"
+`)
+
+	rejectCallback := &testUpdateCallback{}
+	env.RegisterDelayedCallback(func() {
+		env.UpdateWorkflow(workflow.PatchVariablesUpdateName, "patch-1", rejectCallback, &workflow.VariablesPatch{
+			Variables: map[string]interface{}{"cdId": "cd-2"},
+		})
+		env.UpdateWorkflow(workflow.SkipStepUpdateName, "skip-1", &testUpdateCallback{},
+			&workflow.StepDecision{ActivityId: "delete-cd"})
+	}, time.Minute)
+
+	var history []*workflow.PatchRecord
+	env.RegisterDelayedCallback(func() {
+		val, err := env.QueryWorkflow(workflow.PatchHistoryQueryName)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_ = val.Get(&history)
+	}, time.Minute+time.Second)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if rejectCallback.err == nil || !strings.Contains(rejectCallback.err.Error(), "started") {
+		t.Fatalf("expected patch rejected, got %v", rejectCallback.err)
+	}
+	if len(history) != 0 {
+		t.Fatalf("rejected patch recorded: %s", conv.String(history))
+	}
+}
+
+func TestDslSearchAttributes(t *testing.T) {
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"search-deploy": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"replicas": "3"}, nil
+		},
+	})
+
+	upserted := make(map[string]interface{})
+	env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+		sa := args.Get(0).(temporal.SearchAttributes)
+		if val, ok := sa.GetKeyword(temporal.NewSearchAttributeKeyKeyword("projectName")); ok {
+			upserted["projectName"] = val
+		}
+		if val, ok := sa.GetInt64(temporal.NewSearchAttributeKeyInt64("replicas")); ok {
+			upserted["replicas"] = val
+		}
+	}).Return(nil)
+
+	dsl := loadDslFromYaml(t, `
+variables:
+  projectName: demo
+searchattributes:
+  - name: projectName
+    value: "{{variables.projectName}}"
+  - name: replicas
+    type: int
+    value: "{{deploy.responses.replicas}}"
+root:
+  activity:
+    id: deploy
+    template: search-deploy
+`)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if upserted["projectName"] != "demo" || upserted["replicas"] != int64(3) {
+		t.Fatalf("unexpected search attributes: %s", conv.String(upserted))
+	}
+}
+
+func TestDslSearchAttributesAfterOnExitReturn(t *testing.T) {
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"search-return-check": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"paasName": "paas-1"}, nil
+		},
+		"search-return-delete": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"status": "deleted"}, nil
+		},
+	})
+
+	var lock sync.Mutex
+	upserted := make([]string, 0)
+	env.OnUpsertTypedSearchAttributes(mock.Anything).Run(func(args mock.Arguments) {
+		sa := args.Get(0).(temporal.SearchAttributes)
+		lock.Lock()
+		defer lock.Unlock()
+		for _, name := range []string{"projectName", "paasName", "status"} {
+			if val, ok := sa.GetKeyword(temporal.NewSearchAttributeKeyKeyword(name)); ok {
+				upserted = append(upserted, name+"="+val)
+			}
+		}
+	}).Return(nil)
+
+	dsl := loadDslFromYaml(t, `
+variables:
+  projectName: demo
+searchattributes:
+  - name: projectName
+    value: "{{variables.projectName}}"
+  - name: paasName
+    value: "{{check.responses.paasName}}"
+  - name: status
+    value: "{{delete.responses.status}}"
+root:
+  control:
+    onexit: "return|exit"
+  activity:
+    id: check
+    template: search-return-check
+  sequence:
+    - activity:
+        id: delete
+        template: search-return-delete
+`)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if ok, _ := cond.Contains(upserted, "status=deleted"); !ok {
+		t.Fatalf("child search attributes not upserted: %v", upserted)
+	}
+}
+
+func TestDslSeedsSkipCompletedSteps(t *testing.T) {
+	var createCalls int
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"seed-create": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			createCalls++
+			return map[string]interface{}{"id": "new"}, nil
+		},
+		"seed-delete": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"deleted": param["id"]}, nil
+		},
+	})
+
+	dsl := loadDslFromYaml(t, `
+root:
+  sequence:
+    - activity:
+        id: create
+        template: seed-create
+    - activity:
+        id: delete
+        template: seed-delete
+        arguments:
+          id: "{{create.responses.id}}"
+responses:
+  deleted: "{{delete.responses.deleted}}"
+`)
+	dsl.Seeds = map[string]*workflow.StepSeed{
+		"create": {Responses: map[string]interface{}{"id": "recorded"}},
+	}
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	ret := make(map[string]interface{})
+	if err := env.GetWorkflowResult(&ret); err != nil {
+		t.Fatal(err)
+	}
+	if createCalls != 0 || conv.String(ret["deleted"]) != "recorded" {
+		t.Fatalf("unexpected rerun result: calls=%d, %s", createCalls, conv.String(ret))
+	}
+}
+
+func TestDslNotifyWebhook(t *testing.T) {
+	const secretEnv = "DSL_TEST_WEBHOOK_SECRET"
+	t.Setenv(secretEnv, "test-secret")
+
+	var requests int
+	payload := new(workflow.NotifyPayload)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		sign := activity.SignWebhook("test-secret", r.Header.Get(activity.WebhookTimestampHeader), body)
+		if sign != r.Header.Get(activity.WebhookSignatureHeader) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_ = json.Unmarshal(body, payload)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"notify-fail": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return nil, fmt.Errorf("backend unavailable")
+		},
+		activity.NotifyWebhookTemplate: activity.NewWebhookActivity().GetMethod(),
+	})
+
+	dsl := loadDslFromYaml(t, `
+name: notify-test
+variables:
+  callbackUrl: `+server.URL+`
+notifications:
+  - url: "{{variables.callbackUrl}}"
+    secretenv: `+secretEnv+`
+root:
+  activity:
+    id: deploy
+    template: notify-fail
+`)
+
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err == nil {
+		t.Fatal("expected workflow error")
+	}
+	if requests != 2 {
+		t.Fatalf("expected webhook retried once, got %d requests", requests)
+	}
+	if payload.Status != workflow.NotifyStatusFailed || payload.DslName != "notify-test" ||
+		!strings.Contains(payload.Failure, "backend unavailable") {
+		t.Fatalf("unexpected payload: %s", conv.String(payload))
+	}
+}
+
+// runOnExitReturnNotify 执行 onExit: return 后子流程失败的流程，返回收到的通知，oldVersion 模拟之前启动的流程重放
+func runOnExitReturnNotify(t *testing.T, oldVersion bool) []*workflow.NotifyPayload {
+	var lock sync.Mutex
+	payloads := make([]*workflow.NotifyPayload, 0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		one := new(workflow.NotifyPayload)
+		_ = json.NewDecoder(r.Body).Decode(one)
+		lock.Lock()
+		payloads = append(payloads, one)
+		lock.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	env := newDslTestEnv(t, map[string]activity.TemplateMethod{
+		"return-check": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"ok": true}, nil
+		},
+		"return-delete": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return nil, temporal.NewNonRetryableApplicationError("delete failed", "test", nil)
+		},
+		activity.NotifyWebhookTemplate: activity.NewWebhookActivity().GetMethod(),
+	})
+
+	dsl := loadDslFromYaml(t, `
+name: return-notify-test
+variables:
+  callbackUrl: `+server.URL+`
+notifications:
+  - url: "{{variables.callbackUrl}}"
+root:
+  control:
+    onexit: "return|exit"
+  activity:
+    id: check
+    template: return-check
+  sequence:
+    - activity:
+        id: delete
+        template: return-delete
+`)
+
+	if oldVersion {
+		env.OnGetVersion("dsl-continue-child", temporalWorkflow.DefaultVersion, 1).Return(temporalWorkflow.DefaultVersion)
+	}
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	return payloads
+}
+
+func TestDslNotifyAfterOnExitReturn(t *testing.T) {
+	payloads := runOnExitReturnNotify(t, false)
+	if len(payloads) != 1 {
+		t.Fatalf("expected one notification, got %s", conv.String(payloads))
+	}
+	if payloads[0].Status != workflow.NotifyStatusFailed || !strings.Contains(payloads[0].Failure, "delete failed") {
+		t.Fatalf("expected child failure notified, got %s", conv.String(payloads[0]))
+	}
+}
+
+// TestDslNotifyAfterOnExitReturnOldVersion 之前启动的流程不等待子流程启动，结束通知仍由父流程发送
+func TestDslNotifyAfterOnExitReturnOldVersion(t *testing.T) {
+	payloads := runOnExitReturnNotify(t, true)
+	statusList := make([]string, 0, len(payloads))
+	for _, one := range payloads {
+		statusList = append(statusList, string(one.Status))
+	}
+	sort.Strings(statusList)
+	if strings.Join(statusList, ",") != string(workflow.NotifyStatusCompleted)+","+string(workflow.NotifyStatusFailed) {
+		t.Fatalf("expected parent and child notifications, got %s", conv.String(payloads))
+	}
+}
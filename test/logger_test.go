@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/tianlin0/temporal/activity"
+	"github.com/tianlin0/temporal/workflow"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	temporalWorkflow "go.temporal.io/sdk/workflow"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordLogger 记录所有日志，一行为 level msg key=val...
+type recordLogger struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *recordLogger) log(level string, msg string, keyvals ...interface{}) {
+	parts := []string{level, msg}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		parts = append(parts, fmt.Sprintf("%v=%v", keyvals[i], keyvals[i+1]))
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, strings.Join(parts, " "))
+}
+
+func (l *recordLogger) Debug(msg string, keyvals ...interface{}) { l.log("DEBUG", msg, keyvals...) }
+func (l *recordLogger) Info(msg string, keyvals ...interface{})  { l.log("INFO", msg, keyvals...) }
+func (l *recordLogger) Warn(msg string, keyvals ...interface{})  { l.log("WARN", msg, keyvals...) }
+func (l *recordLogger) Error(msg string, keyvals ...interface{}) { l.log("ERROR", msg, keyvals...) }
+
+// find 返回第一行包含所有内容的日志
+func (l *recordLogger) find(contains ...string) string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, line := range l.lines {
+		matched := true
+		for _, one := range contains {
+			if !strings.Contains(line, one) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return line
+		}
+	}
+	return ""
+}
+
+func TestDslLogger(t *testing.T) {
+	logger := new(recordLogger)
+	s := &testsuite.WorkflowTestSuite{}
+	s.SetLogger(logger)
+
+	env := newDslTestEnvWithSuite(t, s, map[string]activity.TemplateMethod{
+		"logger-deploy": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			activity.GetLogger(ctx).Info("deploy running")
+			return map[string]interface{}{"ok": true}, nil
+		},
+	})
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{workflow.NewMetricsInterceptor()},
+	})
+
+	dsl := loadDslFromYaml(t, `
+name: logger-test
+root:
+  sequence:
+    - activity:
+        id: deploy
+        template: logger-deploy
+`)
+	env.ExecuteWorkflow("DslWorkflow", &temporalWorkflow.ActivityOptions{StartToCloseTimeout: time.Minute}, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+
+	if line := logger.find("logger-deploy deploy param:", "DslName=logger-test", "ActivityID=deploy"); line == "" {
+		t.Fatalf("workflow log missing fields:\n%s", strings.Join(logger.lines, "\n"))
+	}
+	if line := logger.find("deploy running", "DslName=logger-test", "ActivityID=deploy", "RunID="); line == "" {
+		t.Fatalf("activity log missing fields:\n%s", strings.Join(logger.lines, "\n"))
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/tianlin0/temporal/activity"
+	"github.com/tianlin0/temporal/metrics"
+	"github.com/tianlin0/temporal/workflow"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	temporalWorkflow "go.temporal.io/sdk/workflow"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDslMetrics(t *testing.T) {
+	handler := metrics.NewHandler()
+	s := &testsuite.WorkflowTestSuite{}
+	s.SetMetricsHandler(handler)
+
+	var calls int
+	env := newDslTestEnvWithSuite(t, s, map[string]activity.TemplateMethod{
+		"metrics-deploy": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			calls++
+			if calls == 1 {
+				return nil, fmt.Errorf("deploy busy")
+			}
+			return map[string]interface{}{"ok": true}, nil
+		},
+	})
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{workflow.NewMetricsInterceptor()},
+	})
+
+	dsl := loadDslFromYaml(t, `
+name: metrics-test
+root:
+  sequence:
+    - activity:
+        id: deploy
+        template: metrics-deploy
+`)
+	actOption := &temporalWorkflow.ActivityOptions{
+		StartToCloseTimeout: time.Minute,
+		RetryPolicy:         &temporal.RetryPolicy{InitialInterval: time.Second, MaximumAttempts: 2},
+	}
+	env.ExecuteWorkflow("DslWorkflow", actOption, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	text := recorder.Body.String()
+	for _, expected := range []string{
+		`dsl_step_total{dsl_name="metrics-test",outcome="completed",task_queue="` + testTaskQueue + `",template="metrics-deploy"} 1`,
+		`dsl_workflow_total{dsl_name="metrics-test",outcome="completed"`,
+		`dsl_step_retries{activity_type="` + testTaskQueue + `/metrics-deploy",dsl_name="metrics-test"`,
+		`# TYPE dsl_step_duration histogram`,
+	} {
+		if !strings.Contains(text, expected) {
+			t.Fatalf("metrics missing %s:\n%s", expected, text)
+		}
+	}
+}
+
+func TestDslMetricsAfterOnExitReturn(t *testing.T) {
+	handler := metrics.NewHandler()
+	s := &testsuite.WorkflowTestSuite{}
+	s.SetMetricsHandler(handler)
+
+	env := newDslTestEnvWithSuite(t, s, map[string]activity.TemplateMethod{
+		"metrics-return-step": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			return map[string]interface{}{"ok": true}, nil
+		},
+	})
+
+	dsl := loadDslFromYaml(t, `
+name: metrics-return-test
+root:
+  control:
+    onexit: "return|exit"
+  activity:
+    id: check
+    template: metrics-return-step
+  sequence:
+    - activity:
+        id: delete
+        template: metrics-return-step
+`)
+	env.ExecuteWorkflow("DslWorkflow", nil, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	workflowTotal := make([]string, 0)
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if strings.HasPrefix(line, `dsl_workflow_total{dsl_name="metrics-return-test"`) {
+			workflowTotal = append(workflowTotal, line)
+		}
+	}
+	if len(workflowTotal) != 1 || !strings.HasSuffix(workflowTotal[0], " 1") {
+		t.Fatalf("expected one workflow counted, got %v", workflowTotal)
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"github.com/tianlin0/plat-lib/logs"
+	"github.com/tianlin0/temporal/activity"
+	"github.com/tianlin0/temporal/propagator"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/testsuite"
+	temporalWorkflow "go.temporal.io/sdk/workflow"
+	"testing"
+	"time"
+)
+
+type testHeader map[string]*commonpb.Payload
+
+func (h testHeader) Set(key string, value *commonpb.Payload) {
+	h[key] = value
+}
+
+func TestContextPropagator(t *testing.T) {
+	p := propagator.NewContextPropagator(&propagator.Options{Keys: []string{"aaa"}})
+
+	_, ctx := logs.NewCtxLogger(context.Background(), logs.INFO, nil, &logs.LogCommData{LogId: "log-123"})
+	ctx = propagator.WithOperator(ctx, "alice")
+	ctx = propagator.WithTenant(ctx, "tenant-a")
+	ctx = context.WithValue(ctx, "aaa", "from-submit")
+	header := testHeader{}
+	if err := p.Inject(ctx, header); err != nil {
+		t.Fatal(err)
+	}
+
+	var got map[string]interface{}
+	env := newDslTestEnvWithSuite(t, &testsuite.WorkflowTestSuite{}, map[string]activity.TemplateMethod{
+		"propagator-read": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			got = map[string]interface{}{
+				"aaa":      ctx.Value("aaa"),
+				"operator": propagator.GetOperator(ctx),
+				"tenant":   propagator.GetTenant(ctx),
+				"logId":    logs.CtxLogger(ctx).LogId(ctx),
+			}
+			return got, nil
+		},
+	})
+	env.SetContextPropagators([]temporalWorkflow.ContextPropagator{p})
+	env.SetHeader(&commonpb.Header{Fields: header})
+
+	dsl := loadDslFromYaml(t, `
+name: propagator-test
+root:
+  sequence:
+    - activity:
+        id: read
+        template: propagator-read
+`)
+	env.ExecuteWorkflow("DslWorkflow", &temporalWorkflow.ActivityOptions{StartToCloseTimeout: time.Minute}, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]interface{}{
+		"aaa":      "from-submit",
+		"operator": "alice",
+		"tenant":   "tenant-a",
+		"logId":    "log-123",
+	}
+	for key, val := range expected {
+		if got[key] != val {
+			t.Fatalf("%s = %v, want %v", key, got[key], val)
+		}
+	}
+}
+
+func (h testHeader) Get(key string) (*commonpb.Payload, bool) {
+	val, ok := h[key]
+	return val, ok
+}
+
+func (h testHeader) ForEachKey(handler func(string, *commonpb.Payload) error) error {
+	for key, val := range h {
+		if err := handler(key, val); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestContextPropagatorWhitelist header中只还原内置的key和配置的key
+func TestContextPropagatorWhitelist(t *testing.T) {
+	ctx := propagator.WithOperator(context.Background(), "alice")
+	ctx = context.WithValue(ctx, "aaa", "allowed")
+	ctx = context.WithValue(ctx, "bbb", "injected")
+	header := testHeader{}
+	//调用方使用更多key的配置写入header
+	if err := propagator.NewContextPropagator(&propagator.Options{Keys: []string{"aaa", "bbb"}}).Inject(ctx, header); err != nil {
+		t.Fatal(err)
+	}
+
+	p := propagator.NewContextPropagator(&propagator.Options{Keys: []string{"aaa"}})
+	newCtx, err := p.Extract(context.Background(), header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if newCtx.Value("aaa") != "allowed" || newCtx.Value("bbb") != nil || propagator.GetOperator(newCtx) != "alice" {
+		t.Fatalf("unexpected ctx values: %v %v %s", newCtx.Value("aaa"), newCtx.Value("bbb"), propagator.GetOperator(newCtx))
+	}
+
+	var got map[string]interface{}
+	env := (&testsuite.WorkflowTestSuite{}).NewTestWorkflowEnvironment()
+	env.RegisterWorkflowWithOptions(func(ctx temporalWorkflow.Context) error {
+		got = map[string]interface{}{
+			"aaa":      ctx.Value("aaa"),
+			"bbb":      ctx.Value("bbb"),
+			"operator": propagator.GetOperator(ctx),
+		}
+		return nil
+	}, temporalWorkflow.RegisterOptions{Name: "whitelist-workflow"})
+	env.SetContextPropagators([]temporalWorkflow.ContextPropagator{p})
+	env.SetHeader(&commonpb.Header{Fields: header})
+	env.ExecuteWorkflow("whitelist-workflow")
+	if err = env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+	if got["aaa"] != "allowed" || got["bbb"] != nil || got["operator"] != "alice" {
+		t.Fatalf("unexpected workflow ctx values: %v", got)
+	}
+}
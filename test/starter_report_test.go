@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"github.com/tianlin0/temporal/starter"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/temporalproto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestHistoryEvent 生成测试用的历史事件
+func newTestHistoryEvent(id int64, t time.Time, eventType enums.EventType, attr interface{}) *historypb.HistoryEvent {
+	event := &historypb.HistoryEvent{
+		EventId:   id,
+		EventTime: timestamppb.New(t),
+		EventType: eventType,
+	}
+	switch a := attr.(type) {
+	case *historypb.WorkflowExecutionStartedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionStartedEventAttributes{WorkflowExecutionStartedEventAttributes: a}
+	case *historypb.ActivityTaskScheduledEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_ActivityTaskScheduledEventAttributes{ActivityTaskScheduledEventAttributes: a}
+	case *historypb.ActivityTaskStartedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_ActivityTaskStartedEventAttributes{ActivityTaskStartedEventAttributes: a}
+	case *historypb.ActivityTaskCompletedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_ActivityTaskCompletedEventAttributes{ActivityTaskCompletedEventAttributes: a}
+	case *historypb.ActivityTaskFailedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_ActivityTaskFailedEventAttributes{ActivityTaskFailedEventAttributes: a}
+	case *historypb.TimerStartedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_TimerStartedEventAttributes{TimerStartedEventAttributes: a}
+	case *historypb.TimerFiredEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_TimerFiredEventAttributes{TimerFiredEventAttributes: a}
+	case *historypb.WorkflowExecutionSignaledEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionSignaledEventAttributes{WorkflowExecutionSignaledEventAttributes: a}
+	case *historypb.ChildWorkflowExecutionStartedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_ChildWorkflowExecutionStartedEventAttributes{ChildWorkflowExecutionStartedEventAttributes: a}
+	case *historypb.WorkflowExecutionFailedEventAttributes:
+		event.Attributes = &historypb.HistoryEvent_WorkflowExecutionFailedEventAttributes{WorkflowExecutionFailedEventAttributes: a}
+	}
+	return event
+}
+
+func TestTimelineFromHistoryFile(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	activityType := &commonpb.ActivityType{Name: testTaskQueue + "/deploy"}
+	history := &historypb.History{Events: []*historypb.HistoryEvent{
+		newTestHistoryEvent(1, t0, enums.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+			&historypb.WorkflowExecutionStartedEventAttributes{}),
+		newTestHistoryEvent(2, t0.Add(time.Second), enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+			&historypb.ActivityTaskScheduledEventAttributes{ActivityId: "deploy", ActivityType: activityType}),
+		newTestHistoryEvent(3, t0.Add(time.Second), enums.EVENT_TYPE_ACTIVITY_TASK_STARTED,
+			&historypb.ActivityTaskStartedEventAttributes{ScheduledEventId: 2, Attempt: 2,
+				LastFailure: &failurepb.Failure{Message: "connect refused"}}),
+		newTestHistoryEvent(4, t0.Add(5*time.Second), enums.EVENT_TYPE_ACTIVITY_TASK_COMPLETED,
+			&historypb.ActivityTaskCompletedEventAttributes{ScheduledEventId: 2}),
+		newTestHistoryEvent(5, t0.Add(5*time.Second), enums.EVENT_TYPE_TIMER_STARTED,
+			&historypb.TimerStartedEventAttributes{TimerId: "6"}),
+		newTestHistoryEvent(6, t0.Add(15*time.Second), enums.EVENT_TYPE_TIMER_FIRED,
+			&historypb.TimerFiredEventAttributes{TimerId: "6"}),
+		newTestHistoryEvent(7, t0.Add(30*time.Second), enums.EVENT_TYPE_WORKFLOW_EXECUTION_SIGNALED,
+			&historypb.WorkflowExecutionSignaledEventAttributes{SignalName: "go-on"}),
+		newTestHistoryEvent(8, t0.Add(30*time.Second), enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+			&historypb.ActivityTaskScheduledEventAttributes{ActivityId: "notify", ActivityType: activityType}),
+		newTestHistoryEvent(9, t0.Add(31*time.Second), enums.EVENT_TYPE_ACTIVITY_TASK_FAILED,
+			&historypb.ActivityTaskFailedEventAttributes{ScheduledEventId: 8,
+				Failure: &failurepb.Failure{Message: "notify failed"}}),
+		newTestHistoryEvent(10, t0.Add(31*time.Second), enums.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED,
+			&historypb.WorkflowExecutionFailedEventAttributes{Failure: &failurepb.Failure{Message: "notify failed"}}),
+	}}
+
+	data, err := temporalproto.CustomJSONMarshalOptions{}.Marshal(history)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "history.json")
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tl, err := starter.BuildTimeline(context.Background(),
+		starter.FileHistorySource{"test-timeline": path}, "test-timeline", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nameList := make([]string, 0)
+	for _, row := range tl.Rows {
+		nameList = append(nameList, row.Name)
+	}
+	if strings.Join(nameList, ",") != "test-timeline,deploy,notify,wait" {
+		t.Fatalf("unexpected rows: %v", nameList)
+	}
+	deployRow, notifyRow, waitRow := tl.Rows[1], tl.Rows[2], tl.Rows[3]
+	if len(deployRow.Markers) != 1 || deployRow.Markers[0].Kind != starter.TimelineMarkerRetry {
+		t.Fatalf("expected retry marker on deploy")
+	}
+	if len(notifyRow.Markers) != 1 || notifyRow.Markers[0].Kind != starter.TimelineMarkerFailure {
+		t.Fatalf("expected failure marker on notify")
+	}
+	hasSignalWait := false
+	for _, bar := range waitRow.Bars {
+		if bar.Kind == starter.TimelineBarWait && bar.Name == "signal go-on" {
+			hasSignalWait = true
+		}
+	}
+	if !hasSignalWait {
+		t.Fatalf("expected wait gap ended by signal")
+	}
+
+	html, err := tl.HTML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(html), "notify failed") {
+		t.Fatalf("html missing failure message")
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"github.com/tianlin0/plat-lib/conv"
+	"github.com/tianlin0/temporal/starter"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/temporalproto"
+	"go.temporal.io/sdk/converter"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeTestHistory 将历史事件写入文件，返回文件路径
+func writeTestHistory(t *testing.T, name string, events ...*historypb.HistoryEvent) string {
+	data, err := temporalproto.CustomJSONMarshalOptions{}.Marshal(&historypb.History{Events: events})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), name+".json")
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newTestCompletedStep 一个执行成功的activity的三个事件
+func newTestCompletedStep(t *testing.T, id int64, t0 time.Time, activityId string,
+	input, output map[string]interface{}) []*historypb.HistoryEvent {
+	dc := converter.GetDefaultDataConverter()
+	inputPayloads, err := dc.ToPayloads(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputPayloads, err := dc.ToPayloads(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	activityType := &commonpb.ActivityType{Name: testTaskQueue + "/" + activityId}
+	return []*historypb.HistoryEvent{
+		newTestHistoryEvent(id, t0, enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+			&historypb.ActivityTaskScheduledEventAttributes{ActivityId: activityId, ActivityType: activityType, Input: inputPayloads}),
+		newTestHistoryEvent(id+1, t0, enums.EVENT_TYPE_ACTIVITY_TASK_STARTED,
+			&historypb.ActivityTaskStartedEventAttributes{ScheduledEventId: id, Attempt: 1}),
+		newTestHistoryEvent(id+2, t0.Add(time.Second), enums.EVENT_TYPE_ACTIVITY_TASK_COMPLETED,
+			&historypb.ActivityTaskCompletedEventAttributes{ScheduledEventId: id, Result: outputPayloads}),
+	}
+}
+
+func TestRerunSeedsFromOnExitChild(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	//根流程只执行了 del-cd-check，之后 onexit: return 启动子流程
+	rootEvents := []*historypb.HistoryEvent{
+		newTestHistoryEvent(1, t0, enums.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+			&historypb.WorkflowExecutionStartedEventAttributes{}),
+	}
+	rootEvents = append(rootEvents, newTestCompletedStep(t, 2, t0, "del-cd-check",
+		map[string]interface{}{"cdId": "cd-1"}, map[string]interface{}{"exists": true})...)
+	rootEvents = append(rootEvents,
+		newTestHistoryEvent(5, t0.Add(2*time.Second), enums.EVENT_TYPE_CHILD_WORKFLOW_EXECUTION_STARTED,
+			&historypb.ChildWorkflowExecutionStartedEventAttributes{
+				WorkflowExecution: &commonpb.WorkflowExecution{WorkflowId: "delete-cd-child", RunId: "child-run"}}),
+		newTestHistoryEvent(6, t0.Add(2*time.Second), enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED, nil))
+
+	childEvents := []*historypb.HistoryEvent{
+		newTestHistoryEvent(1, t0.Add(3*time.Second), enums.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+			&historypb.WorkflowExecutionStartedEventAttributes{}),
+	}
+	id := int64(2)
+	for _, stepId := range []string{"del-cd-recycling", "del-cd-auto-trigger", "del-cd-log", "del-cd-grayscale", "del-cd-alarm"} {
+		childEvents = append(childEvents, newTestCompletedStep(t, id, t0.Add(3*time.Second), stepId,
+			map[string]interface{}{"cdId": "cd-1"}, map[string]interface{}{"step": stepId})...)
+		id += 3
+	}
+	activityType := &commonpb.ActivityType{Name: testTaskQueue + "/del-cd-incluster-service"}
+	childEvents = append(childEvents,
+		newTestHistoryEvent(id, t0.Add(10*time.Second), enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+			&historypb.ActivityTaskScheduledEventAttributes{ActivityId: "del-cd-incluster-service", ActivityType: activityType}),
+		newTestHistoryEvent(id+1, t0.Add(11*time.Second), enums.EVENT_TYPE_ACTIVITY_TASK_FAILED,
+			&historypb.ActivityTaskFailedEventAttributes{ScheduledEventId: id, Failure: &failurepb.Failure{Message: "service busy"}}),
+		newTestHistoryEvent(id+2, t0.Add(11*time.Second), enums.EVENT_TYPE_WORKFLOW_EXECUTION_FAILED,
+			&historypb.WorkflowExecutionFailedEventAttributes{Failure: &failurepb.Failure{Message: "service busy"}}))
+
+	source := starter.FileHistorySource{
+		"delete-cd":       writeTestHistory(t, "root", rootEvents...),
+		"delete-cd-child": writeTestHistory(t, "child", childEvents...),
+	}
+	seeds, err := starter.GetRerunSeeds(context.Background(), source, "delete-cd", "", "del-cd-incluster-service")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idList := make([]string, 0, len(seeds))
+	for _, stepId := range []string{"del-cd-check", "del-cd-recycling", "del-cd-auto-trigger", "del-cd-log",
+		"del-cd-grayscale", "del-cd-alarm"} {
+		if _, ok := seeds[stepId]; ok {
+			idList = append(idList, stepId)
+		}
+	}
+	if len(idList) != 6 || len(seeds) != 6 {
+		t.Fatalf("unexpected seeds: %s", conv.String(seeds))
+	}
+	if seeds["del-cd-check"].Responses["exists"] != true || seeds["del-cd-alarm"].Responses["step"] != "del-cd-alarm" {
+		t.Fatalf("unexpected seed values: %s", conv.String(seeds))
+	}
+
+	if _, err = starter.GetRerunSeeds(context.Background(), source, "delete-cd", "", "del-cd-missing"); err == nil ||
+		!strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+}
+
+// TestRerunSeedsRepeatedAndParallel activityId 之前重复执行成功的activity无法生成seed，
+// 并行分支中在 activityId 调度之后才结束的activity不生成seed
+func TestRerunSeedsRepeatedAndParallel(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	activityType := &commonpb.ActivityType{Name: testTaskQueue + "/del-cd-slow"}
+
+	//del-cd-check 和 del-cd-slow 并行，del-cd-slow 在 del-cd-service 调度之后才结束
+	events := []*historypb.HistoryEvent{
+		newTestHistoryEvent(1, t0, enums.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+			&historypb.WorkflowExecutionStartedEventAttributes{}),
+		newTestHistoryEvent(2, t0, enums.EVENT_TYPE_ACTIVITY_TASK_SCHEDULED,
+			&historypb.ActivityTaskScheduledEventAttributes{ActivityId: "del-cd-slow", ActivityType: activityType}),
+	}
+	events = append(events, newTestCompletedStep(t, 3, t0, "del-cd-check",
+		map[string]interface{}{"cdId": "cd-1"}, map[string]interface{}{"exists": true})...)
+	events = append(events, newTestCompletedStep(t, 6, t0.Add(2*time.Second), "del-cd-service",
+		map[string]interface{}{"cdId": "cd-1"}, map[string]interface{}{"deleted": true})...)
+	events = append(events,
+		newTestHistoryEvent(9, t0.Add(5*time.Second), enums.EVENT_TYPE_ACTIVITY_TASK_COMPLETED,
+			&historypb.ActivityTaskCompletedEventAttributes{ScheduledEventId: 2}),
+		newTestHistoryEvent(10, t0.Add(5*time.Second), enums.EVENT_TYPE_WORKFLOW_EXECUTION_COMPLETED, nil))
+
+	source := starter.FileHistorySource{"parallel": writeTestHistory(t, "parallel", events...)}
+	seeds, err := starter.GetRerunSeeds(context.Background(), source, "parallel", "", "del-cd-service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seeds) != 1 || seeds["del-cd-check"] == nil {
+		t.Fatalf("unexpected seeds: %s", conv.String(seeds))
+	}
+
+	//del-cd-poll 在 del-cd-service 之前执行了两次
+	events = []*historypb.HistoryEvent{
+		newTestHistoryEvent(1, t0, enums.EVENT_TYPE_WORKFLOW_EXECUTION_STARTED,
+			&historypb.WorkflowExecutionStartedEventAttributes{}),
+	}
+	events = append(events, newTestCompletedStep(t, 2, t0, "del-cd-poll",
+		map[string]interface{}{"cdId": "cd-1"}, map[string]interface{}{"ready": false})...)
+	events = append(events, newTestCompletedStep(t, 5, t0.Add(2*time.Second), "del-cd-poll#2",
+		map[string]interface{}{"cdId": "cd-1"}, map[string]interface{}{"ready": true})...)
+	events = append(events, newTestCompletedStep(t, 8, t0.Add(4*time.Second), "del-cd-service",
+		map[string]interface{}{"cdId": "cd-1"}, map[string]interface{}{"deleted": true})...)
+
+	source = starter.FileHistorySource{"repeated": writeTestHistory(t, "repeated", events...)}
+	if _, err = starter.GetRerunSeeds(context.Background(), source, "repeated", "", "del-cd-service"); err == nil ||
+		!strings.Contains(err.Error(), "more than once") {
+		t.Fatalf("expected repeated step error, got %v", err)
+	}
+	//从重复执行的activity开始重新执行时，之前没有重复的activity
+	if _, err = starter.GetRerunSeeds(context.Background(), source, "repeated", "", "del-cd-poll"); err != nil {
+		t.Fatal(err)
+	}
+}
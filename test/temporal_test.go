@@ -11,6 +11,7 @@ import (
 	"github.com/tianlin0/temporal/starter"
 	act "github.com/tianlin0/temporal/test/activity"
 	"github.com/tianlin0/temporal/workflow"
+	"gopkg.in/yaml.v3"
 	"log"
 	"os"
 	"testing"
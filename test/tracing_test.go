@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"github.com/tianlin0/temporal/activity"
+	"github.com/tianlin0/temporal/tracing"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	temporalWorkflow "go.temporal.io/sdk/workflow"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDslTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	traceparent := ""
+	env := newDslTestEnvWithSuite(t, &testsuite.WorkflowTestSuite{}, map[string]activity.TemplateMethod{
+		"tracing-deploy": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+			header := http.Header{}
+			tracing.InjectHTTPHeader(ctx, header)
+			traceparent = header.Get("traceparent")
+			return map[string]interface{}{"ok": true}, nil
+		},
+	})
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{tracing.NewInterceptor(&tracing.Options{Tracer: tp.Tracer("test")})},
+	})
+
+	dsl := loadDslFromYaml(t, `
+name: tracing-test
+root:
+  sequence:
+    - activity:
+        id: deploy
+        template: tracing-deploy
+`)
+	env.ExecuteWorkflow("DslWorkflow", &temporalWorkflow.ActivityOptions{StartToCloseTimeout: time.Minute}, dsl)
+	if err := env.GetWorkflowError(); err != nil {
+		t.Fatal(err)
+	}
+
+	var startSpan, runSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		switch {
+		case strings.HasPrefix(span.Name(), "StartActivity:deploy"):
+			startSpan = span
+		case strings.HasPrefix(span.Name(), "RunActivity:deploy"):
+			runSpan = span
+		}
+	}
+	if startSpan == nil || runSpan == nil {
+		t.Fatalf("activity spans missing: %+v", recorder.Ended())
+	}
+	if runSpan.Parent().SpanID() != startSpan.SpanContext().SpanID() {
+		t.Fatalf("RunActivity parent %s, want %s", runSpan.Parent().SpanID(), startSpan.SpanContext().SpanID())
+	}
+	if !strings.Contains(traceparent, runSpan.SpanContext().TraceID().String()) {
+		t.Fatalf("traceparent %q not in trace %s", traceparent, runSpan.SpanContext().TraceID())
+	}
+}
+
+// TestDslTracingParentAfterRestart 流程不持有未结束的span，换worker重新执行时activity的span使用相同的父上下文
+func TestDslTracingParentAfterRestart(t *testing.T) {
+	dsl := loadDslFromYaml(t, `
+name: tracing-restart-test
+root:
+  sequence:
+    - activity:
+        id: deploy
+        template: tracing-restart-deploy
+`)
+	runOnce := func() *tracetest.SpanRecorder {
+		recorder := tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder),
+			sdktrace.WithIDGenerator(tracing.NewIDGenerator()))
+		env := newDslTestEnvWithSuite(t, &testsuite.WorkflowTestSuite{}, map[string]activity.TemplateMethod{
+			"tracing-restart-deploy": func(ctx context.Context, param map[string]interface{}) (map[string]interface{}, error) {
+				return map[string]interface{}{"ok": true}, nil
+			},
+		})
+		env.SetWorkerOptions(worker.Options{
+			Interceptors: []interceptor.WorkerInterceptor{tracing.NewInterceptor(&tracing.Options{Tracer: tp.Tracer("test")})},
+		})
+		env.ExecuteWorkflow("DslWorkflow", &temporalWorkflow.ActivityOptions{StartToCloseTimeout: time.Minute}, dsl)
+		if err := env.GetWorkflowError(); err != nil {
+			t.Fatal(err)
+		}
+		return recorder
+	}
+	findSpan := func(recorder *tracetest.SpanRecorder, prefix string) sdktrace.ReadOnlySpan {
+		var found sdktrace.ReadOnlySpan
+		for _, span := range recorder.Ended() {
+			if strings.HasPrefix(span.Name(), prefix) {
+				if found != nil {
+					t.Fatalf("span %s recorded twice", prefix)
+				}
+				found = span
+			}
+		}
+		if found == nil {
+			t.Fatalf("span %s missing: %+v", prefix, recorder.Ended())
+		}
+		return found
+	}
+
+	first, second := runOnce(), runOnce()
+	firstStart := findSpan(first, "StartActivity:deploy")
+	secondStart := findSpan(second, "StartActivity:deploy")
+	if !firstStart.Parent().IsValid() || !firstStart.Parent().Equal(secondStart.Parent()) {
+		t.Fatalf("activity parent changed: %s %s", firstStart.Parent().SpanID(), secondStart.Parent().SpanID())
+	}
+	// RunWorkflow 是activity span的父span，重新执行时span id不变
+	for _, recorder := range []*tracetest.SpanRecorder{first, second} {
+		runSpan := findSpan(recorder, "RunWorkflow:DslWorkflow")
+		if runSpan.SpanContext().SpanID() != firstStart.Parent().SpanID() ||
+			runSpan.SpanContext().TraceID() != firstStart.SpanContext().TraceID() {
+			t.Fatalf("RunWorkflow span %s is not the activity parent %s",
+				runSpan.SpanContext().SpanID(), firstStart.Parent().SpanID())
+		}
+	}
+}
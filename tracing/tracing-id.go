@@ -0,0 +1,50 @@
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type spanIdKey struct{}
+
+// idGenerator ctx 中指定了span上下文时使用它的 trace id 和 span id，否则随机生成
+type idGenerator struct{}
+
+// NewIDGenerator 新建 TracerProvider 使用的id生成器，通过 sdktrace.WithIDGenerator 设置后，
+// 流程的 RunWorkflow span 使用由流程id和run id生成的固定 span id，流程中activity、子流程的span都是它的子span
+func NewIDGenerator() sdktrace.IDGenerator {
+	return &idGenerator{}
+}
+
+// withSpanId 指定ctx中新建span的 trace id 和 span id，只在使用 NewIDGenerator 时生效
+func withSpanId(ctx context.Context, sc trace.SpanContext) context.Context {
+	return context.WithValue(ctx, spanIdKey{}, sc)
+}
+
+func (g *idGenerator) NewIDs(ctx context.Context) (trace.TraceID, trace.SpanID) {
+	if sc, ok := ctx.Value(spanIdKey{}).(trace.SpanContext); ok && sc.IsValid() {
+		return sc.TraceID(), sc.SpanID()
+	}
+	var traceId trace.TraceID
+	var spanId trace.SpanID
+	for !traceId.IsValid() {
+		_, _ = rand.Read(traceId[:])
+	}
+	for !spanId.IsValid() {
+		_, _ = rand.Read(spanId[:])
+	}
+	return traceId, spanId
+}
+
+func (g *idGenerator) NewSpanID(ctx context.Context, traceId trace.TraceID) trace.SpanID {
+	if sc, ok := ctx.Value(spanIdKey{}).(trace.SpanContext); ok && sc.IsValid() && sc.TraceID() == traceId {
+		return sc.SpanID()
+	}
+	var spanId trace.SpanID
+	for !spanId.IsValid() {
+		_, _ = rand.Read(spanId[:])
+	}
+	return spanId
+}
@@ -0,0 +1,150 @@
+package tracing
+
+import (
+	"context"
+	"crypto/sha256"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+	"regexp"
+)
+
+type spanContextKey struct{}
+
+var (
+	// defaultActivityIdRegexp temporal默认生成的activity id为数字，不是DSL中的id
+	defaultActivityIdRegexp = regexp.MustCompile(`^\d+$`)
+)
+
+// getSpanName DSL中的activity使用id命名，没有id时使用类型
+func getSpanName(prefix string, activityId string, activityType string) string {
+	if activityId != "" && !defaultActivityIdRegexp.MatchString(activityId) {
+		return prefix + activityId
+	}
+	return prefix + activityType
+}
+
+// getWorkflowSpanContext 流程中当前的 trace 上下文
+func getWorkflowSpanContext(ctx workflow.Context) context.Context {
+	if sc, ok := ctx.Value(spanContextKey{}).(trace.SpanContext); ok && sc.IsValid() {
+		return trace.ContextWithRemoteSpanContext(context.Background(), sc)
+	}
+	return context.Background()
+}
+
+func (t *tracingInterceptor) InterceptWorkflow(_ workflow.Context,
+	next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	i := &tracingWorkflowInbound{root: t}
+	i.Next = next
+	return i
+}
+
+type tracingWorkflowInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+	root *tracingInterceptor
+}
+
+func (w *tracingWorkflowInbound) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	o := &tracingWorkflowOutbound{root: w.root}
+	o.Next = outbound
+	return w.Next.Init(o)
+}
+
+// ExecuteWorkflow 流程中不持有未结束的span，重放、换worker执行时 trace 上下文保持不变
+// 流程的span在结束时记录一次，开始时间为流程的开始时间，span id 固定，流程中activity、子流程的span都是它的子span
+func (w *tracingWorkflowInbound) ExecuteWorkflow(ctx workflow.Context, in *interceptor.ExecuteWorkflowInput) (interface{}, error) {
+	info := workflow.GetInfo(ctx)
+	parentCtx := w.root.extract(context.Background(), interceptor.WorkflowHeader(ctx))
+	sc := getWorkflowRunSpanContext(trace.SpanContextFromContext(parentCtx), info)
+	ctx = workflow.WithValue(ctx, spanContextKey{}, sc)
+	ret, err := w.Next.ExecuteWorkflow(ctx, in)
+	if !workflow.IsReplaying(ctx) {
+		_, span := w.root.tracer.Start(withSpanId(parentCtx, sc),
+			"RunWorkflow:"+info.WorkflowType.Name,
+			trace.WithSpanKind(trace.SpanKindServer), trace.WithTimestamp(info.WorkflowStartTime),
+			trace.WithAttributes(attribute.String(AttrWorkflow, info.WorkflowExecution.ID),
+				attribute.String(AttrRunId, info.WorkflowExecution.RunID)))
+		endSpan(span, err, trace.WithTimestamp(workflow.Now(ctx)))
+	}
+	return ret, err
+}
+
+// getWorkflowRunSpanContext 流程span的上下文，span id 由流程id和run id生成，
+// trace id 使用header中提交流程时的 trace 上下文，header中没有时也由流程id和run id生成，同一次执行的所有span在一个trace中
+func getWorkflowRunSpanContext(parent trace.SpanContext, info *workflow.Info) trace.SpanContext {
+	sum := sha256.Sum256([]byte(info.WorkflowExecution.ID + "/" + info.WorkflowExecution.RunID))
+	var traceId trace.TraceID
+	var spanId trace.SpanID
+	copy(traceId[:], sum[:16])
+	copy(spanId[:], sum[16:24])
+	flags := trace.FlagsSampled
+	if parent.IsValid() {
+		traceId = parent.TraceID()
+		flags = parent.TraceFlags()
+	}
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: flags,
+		Remote:     true,
+	})
+}
+
+type tracingWorkflowOutbound struct {
+	interceptor.WorkflowOutboundInterceptorBase
+	root *tracingInterceptor
+}
+
+// startSpan 调度activity、子流程时记录span，并将它的 trace 上下文写入header，重放时不记录
+func (o *tracingWorkflowOutbound) startSpan(ctx workflow.Context, name string, attrs ...attribute.KeyValue) {
+	spanCtx := getWorkflowSpanContext(ctx)
+	if !workflow.IsReplaying(ctx) {
+		var span trace.Span
+		spanCtx, span = o.root.tracer.Start(spanCtx, name, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithTimestamp(workflow.Now(ctx)), trace.WithAttributes(attrs...))
+		span.End(trace.WithTimestamp(workflow.Now(ctx)))
+	}
+	o.root.inject(spanCtx, interceptor.WorkflowHeader(ctx))
+}
+
+func (o *tracingWorkflowOutbound) ExecuteActivity(ctx workflow.Context, activityType string, args ...interface{}) workflow.Future {
+	activityId := workflow.GetActivityOptions(ctx).ActivityID
+	o.startSpan(ctx, getSpanName("StartActivity:", activityId, activityType), attribute.String(AttrActivity, activityId))
+	return o.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+func (o *tracingWorkflowOutbound) ExecuteChildWorkflow(ctx workflow.Context, childWorkflowType string,
+	args ...interface{}) workflow.ChildWorkflowFuture {
+	childId := workflow.GetChildWorkflowOptions(ctx).WorkflowID
+	o.startSpan(ctx, "StartChildWorkflow:"+childWorkflowType, attribute.String(AttrWorkflow, childId))
+	return o.Next.ExecuteChildWorkflow(ctx, childWorkflowType, args...)
+}
+
+func (t *tracingInterceptor) InterceptActivity(_ context.Context,
+	next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	i := &tracingActivityInbound{root: t}
+	i.Next = next
+	return i
+}
+
+type tracingActivityInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+	root *tracingInterceptor
+}
+
+// ExecuteActivity activity执行时的span放到ctx中，activity中发送的http请求可以使用 InjectHTTPHeader 传递
+func (a *tracingActivityInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	info := activity.GetInfo(ctx)
+	ctx = a.root.extract(ctx, interceptor.Header(ctx))
+	ctx, span := a.root.tracer.Start(ctx, getSpanName("RunActivity:", info.ActivityID, info.ActivityType.Name),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String(AttrWorkflow, info.WorkflowExecution.ID),
+			attribute.String(AttrRunId, info.WorkflowExecution.RunID),
+			attribute.String(AttrActivity, info.ActivityID),
+			attribute.Int("temporal.attempt", int(info.Attempt))))
+	ret, err := a.Next.ExecuteActivity(ctx, in)
+	endSpan(span, err)
+	return ret, err
+}
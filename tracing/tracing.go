@@ -0,0 +1,149 @@
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"net/http"
+)
+
+const (
+	TracerName   = "github.com/tianlin0/temporal"
+	HeaderKey    = "_tracer-data" //temporal header 中保存 trace 上下文的key
+	AttrWorkflow = "temporal.workflowId"
+	AttrRunId    = "temporal.runId"
+	AttrActivity = "temporal.activityId"
+)
+
+var (
+	// httpPropagator 向外发送http请求时使用W3C traceparent
+	httpPropagator = propagation.TraceContext{}
+)
+
+// Options 链路追踪的配置
+type Options struct {
+	Tracer     trace.Tracer                  //为空时使用 otel 全局的 TracerProvider
+	Propagator propagation.TextMapPropagator //在 temporal header 中传递 trace 上下文的方式，默认W3C traceparent
+}
+
+// tracingInterceptor 实现client、workflow、activity的拦截器
+type tracingInterceptor struct {
+	interceptor.InterceptorBase
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+// NewInterceptor 新建链路追踪的拦截器，设置到 starter.Config.Interceptors 后，提交流程、流程、activity 都会记录span
+// span 使用 DSL 中 activity 的id命名，activity 中通过 curl 发送的请求会带上 traceparent
+// TracerProvider 需要使用 NewIDGenerator 生成id，RunWorkflow span 才是流程中activity、子流程span的父span
+func NewInterceptor(opts *Options) interceptor.Interceptor {
+	t := new(tracingInterceptor)
+	if opts != nil {
+		t.tracer = opts.Tracer
+		t.propagator = opts.Propagator
+	}
+	if t.tracer == nil {
+		t.tracer = otel.Tracer(TracerName)
+	}
+	if t.propagator == nil {
+		t.propagator = propagation.TraceContext{}
+	}
+	return t
+}
+
+// InjectHTTPHeader 将ctx中的 trace 上下文以W3C traceparent 的格式写入http请求的header
+func InjectHTTPHeader(ctx context.Context, header http.Header) {
+	if ctx == nil || header == nil {
+		return
+	}
+	httpPropagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// inject 将 trace 上下文写入 temporal header
+func (t *tracingInterceptor) inject(ctx context.Context, header map[string]*commonpb.Payload) {
+	if header == nil {
+		return
+	}
+	carrier := propagation.MapCarrier{}
+	t.propagator.Inject(ctx, carrier)
+	if len(carrier) == 0 {
+		return
+	}
+	payload, err := converter.GetDefaultDataConverter().ToPayload(map[string]string(carrier))
+	if err != nil {
+		return
+	}
+	header[HeaderKey] = payload
+}
+
+// extract 从 temporal header 中读取 trace 上下文
+func (t *tracingInterceptor) extract(ctx context.Context, header map[string]*commonpb.Payload) context.Context {
+	payload, ok := header[HeaderKey]
+	if !ok {
+		return ctx
+	}
+	carrier := propagation.MapCarrier{}
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &carrier); err != nil {
+		return ctx
+	}
+	return t.propagator.Extract(ctx, carrier)
+}
+
+// endSpan 记录错误后结束span
+func endSpan(span trace.Span, err error, opts ...trace.SpanEndOption) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(opts...)
+}
+
+func (t *tracingInterceptor) InterceptClient(next interceptor.ClientOutboundInterceptor) interceptor.ClientOutboundInterceptor {
+	i := &tracingClientOutbound{root: t}
+	i.Next = next
+	return i
+}
+
+type tracingClientOutbound struct {
+	interceptor.ClientOutboundInterceptorBase
+	root *tracingInterceptor
+}
+
+func (c *tracingClientOutbound) ExecuteWorkflow(ctx context.Context,
+	in *interceptor.ClientExecuteWorkflowInput) (client.WorkflowRun, error) {
+	ctx, span := c.root.tracer.Start(ctx, "StartWorkflow:"+in.WorkflowType, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String(AttrWorkflow, in.Options.ID)))
+	c.root.inject(ctx, interceptor.Header(ctx))
+	run, err := c.Next.ExecuteWorkflow(ctx, in)
+	if err == nil {
+		span.SetAttributes(attribute.String(AttrRunId, run.GetRunID()))
+	}
+	endSpan(span, err)
+	return run, err
+}
+
+func (c *tracingClientOutbound) SignalWorkflow(ctx context.Context, in *interceptor.ClientSignalWorkflowInput) error {
+	ctx, span := c.root.tracer.Start(ctx, "SignalWorkflow:"+in.SignalName, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String(AttrWorkflow, in.WorkflowID)))
+	c.root.inject(ctx, interceptor.Header(ctx))
+	err := c.Next.SignalWorkflow(ctx, in)
+	endSpan(span, err)
+	return err
+}
+
+func (c *tracingClientOutbound) SignalWithStartWorkflow(ctx context.Context,
+	in *interceptor.ClientSignalWithStartWorkflowInput) (client.WorkflowRun, error) {
+	ctx, span := c.root.tracer.Start(ctx, "SignalWithStartWorkflow:"+in.WorkflowType, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String(AttrWorkflow, in.Options.ID)))
+	c.root.inject(ctx, interceptor.Header(ctx))
+	run, err := c.Next.SignalWithStartWorkflow(ctx, in)
+	endSpan(span, err)
+	return run, err
+}
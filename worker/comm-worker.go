@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"github.com/tianlin0/plat-lib/conv"
 	"github.com/tianlin0/plat-lib/logs"
-	"github.com/tianlin0/plat-lib/utils"
 	"github.com/tianlin0/temporal/activity"
+	"go.temporal.io/api/enums/v1"
 	act "go.temporal.io/sdk/activity"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/worker"
@@ -47,6 +47,20 @@ func (cw *commWork) RegisterActivityList(activityList []activity.TemplateActivit
 
 	hasRegisterActName := make([]string, 0)
 
+	//内置的activity，已经有同名的则不注册
+	for _, builtinAct := range []activity.TemplateActivity{activity.NewWebhookActivity()} {
+		found := false
+		for _, oneAct := range activityList {
+			if oneAct.Template() == builtinAct.Template() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			activityList = append(activityList, builtinAct)
+		}
+	}
+
 	for i, oneAct := range activityList {
 		name := oneAct.Template()
 		actFunc := oneAct.GetMethod()
@@ -116,16 +130,18 @@ func (cw *commWork) Start(useRun bool) error {
 
 // ExecuteWorkflow 执行一条workflow
 func (cw *commWork) ExecuteWorkflow(ctx context.Context, workflowId string, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
-	if workflowId == "" {
-		workflowId = utils.NewUUID()
-	} else {
-		workflowId = fmt.Sprintf("%s-%s", workflowId, utils.GetRandomString(5))
-	}
-	workflowId = fmt.Sprintf("%s/%s", cw.queueName, workflowId)
+	return cw.ExecuteWorkflowWithOptions(ctx, &SubmitOptions{WorkflowId: workflowId}, workflow, args...)
+}
 
-	workflowOptions := client.StartWorkflowOptions{
-		ID:        workflowId,
-		TaskQueue: cw.queueName,
+// ExecuteWorkflowWithOptions 根据配置执行一条workflow
+// WorkflowIDConflictPolicy 为 FAIL（IdPolicyExact、IdPolicyTemplate 的默认值）时，相同id的流程已存在则返回 *WorkflowAlreadyRunningError
+func (cw *commWork) ExecuteWorkflowWithOptions(ctx context.Context, opts *SubmitOptions, workflow interface{}, args ...interface{}) (client.WorkflowRun, error) {
+	if opts == nil {
+		opts = &SubmitOptions{}
+	}
+	workflowOptions, err := cw.getStartWorkflowOptions(opts)
+	if err != nil {
+		return nil, err
 	}
 
 	if ctx == nil {
@@ -134,10 +150,21 @@ func (cw *commWork) ExecuteWorkflow(ctx context.Context, workflowId string, work
 
 	logger := logs.CtxLogger(ctx)
 
-	logger.Info("ExecuteWorkflow:", cw.queueName, workflowId)
+	logger.Info("ExecuteWorkflow:", cw.queueName, workflowOptions.ID)
 
 	we, err := cw.client.ExecuteWorkflow(ctx, workflowOptions, workflow, args...)
 	if err != nil {
+		if startedErr, ok := getAlreadyStartedError(err); ok {
+			if opts.getConflictPolicy() == enums.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING {
+				logger.Info("ExecuteWorkflow use existing:", workflowOptions.ID, startedErr.RunId)
+				return cw.client.GetWorkflow(ctx, workflowOptions.ID, startedErr.RunId), nil
+			}
+			return nil, &WorkflowAlreadyRunningError{
+				WorkflowId: workflowOptions.ID,
+				RunId:      startedErr.RunId,
+				Err:        err,
+			}
+		}
 		logger.Error("Unable to execute workflow", err)
 		//这里表示连接不上temporal服务器，需要将错误内容进行具体化。
 		return nil, fmt.Errorf("unable to execute workflow, please check temporal server: %s", err.Error())
@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/mock"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/mocks"
+	"testing"
+)
+
+func TestExecuteWorkflowAlreadyRunning(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        *SubmitOptions
+		useExisting bool
+	}{
+		{name: "exact default fail", opts: &SubmitOptions{WorkflowId: "delete-cd/cd-1", IdPolicy: IdPolicyExact}},
+		{name: "template default fail", opts: &SubmitOptions{WorkflowId: "cd-1", IdPolicy: IdPolicyTemplate, IdTemplate: "delete-cd/{{id}}"}},
+		{name: "exact use existing", opts: &SubmitOptions{WorkflowId: "delete-cd/cd-1", IdPolicy: IdPolicyExact,
+			WorkflowIDConflictPolicy: enums.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING}, useExisting: true},
+		{name: "idempotency key", opts: &SubmitOptions{WorkflowId: "cd-1", IdempotencyKey: "key-1"}, useExisting: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &mocks.Client{}
+			existing := &mocks.WorkflowRun{}
+			startedErr := serviceerror.NewWorkflowExecutionAlreadyStarted("already started", "", "run-1")
+			var startOptions client.StartWorkflowOptions
+			c.On("ExecuteWorkflow", mock.Anything, mock.Anything, "DslWorkflow").
+				Run(func(args mock.Arguments) {
+					startOptions = args.Get(1).(client.StartWorkflowOptions)
+				}).Return(nil, startedErr)
+			c.On("GetWorkflow", mock.Anything, mock.Anything, "run-1").Return(existing)
+			cw := &commWork{client: c, queueName: "queue"}
+
+			run, err := cw.ExecuteWorkflowWithOptions(context.Background(), tt.opts, "DslWorkflow")
+			if !startOptions.WorkflowExecutionErrorWhenAlreadyStarted {
+				t.Fatalf("WorkflowExecutionErrorWhenAlreadyStarted not set: %+v", startOptions)
+			}
+			if tt.useExisting {
+				if err != nil || run != existing {
+					t.Fatalf("expected existing run, got %v %v", run, err)
+				}
+				return
+			}
+			var runningErr *WorkflowAlreadyRunningError
+			if !errors.As(err, &runningErr) {
+				t.Fatalf("expected WorkflowAlreadyRunningError, got %v", err)
+			}
+			if runningErr.WorkflowId != startOptions.ID || runningErr.RunId != "run-1" || !errors.Is(err, startedErr) {
+				t.Fatalf("unexpected error: %+v", runningErr)
+			}
+			c.AssertNotCalled(t, "GetWorkflow", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package worker
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/tianlin0/plat-lib/templates"
+	"github.com/tianlin0/plat-lib/utils"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/serviceerror"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/temporal"
+	"time"
+)
+
+// IdPolicy workflowId 的生成方式
+type IdPolicy int
+
+const (
+	IdPolicyRandomSuffix IdPolicy = iota //默认：queue/<id>-<5位随机字符>，id为空时为 queue/<uuid>
+	IdPolicyExact                        //直接使用传入的id，可以使用业务主键，如 delete-cd/<cdId>
+	IdPolicyTemplate                     //使用 IdTemplate 生成
+)
+
+// SubmitOptions 提交流程的配置
+type SubmitOptions struct {
+	WorkflowId string
+	IdPolicy   IdPolicy
+	IdTemplate string //IdPolicyTemplate 时使用，可用变量：{{queue}} {{id}} {{random}} {{uuid}}
+
+	WorkflowIDReusePolicy enums.WorkflowIdReusePolicy
+	// WorkflowIDConflictPolicy 相同id的流程正在运行时的处理方式：
+	// FAIL 返回 *WorkflowAlreadyRunningError；USE_EXISTING 返回正在运行的流程；TERMINATE_EXISTING 结束正在运行的流程后重新启动
+	// 为空时：IdPolicyExact、IdPolicyTemplate 默认为 FAIL，避免业务主键的流程被误认为提交成功；设置了 IdempotencyKey 时默认为 USE_EXISTING
+	// TERMINATE_EXISTING 通过 TERMINATE_IF_RUNNING 实现，不能同时设置其他的 WorkflowIDReusePolicy
+	WorkflowIDConflictPolicy enums.WorkflowIdConflictPolicy
+
+	WorkflowExecutionTimeout time.Duration
+	WorkflowRunTimeout       time.Duration
+	Memo                     map[string]interface{}
+	SearchAttributes         temporal.SearchAttributes
+	StartDelay               time.Duration
+	CronSchedule             string
+
+	// IdempotencyKey 调用方提供的幂等key，相同key重复提交时返回同一个流程
+	// workflowId 由key生成，只能与默认的 IdPolicyRandomSuffix 一起使用；默认使用 USE_EXISTING 和 REJECT_DUPLICATE，流程结束后重复提交也返回原流程
+	// key 会记录在memo的 idempotencyKey 中
+	IdempotencyKey string
+}
+
+const (
+	IdempotencyKeyMemoKey = "idempotencyKey" //幂等key在memo中的key
+)
+
+// GetIdempotencyWorkflowId 根据幂等key生成workflowId，可以通过key直接查找到流程
+func GetIdempotencyWorkflowId(queueName string, idempotencyKey string) string {
+	sum := sha256.Sum256([]byte(idempotencyKey))
+	return fmt.Sprintf("%s/idempotency/%s", queueName, hex.EncodeToString(sum[:16]))
+}
+
+// getConflictPolicy 实际使用的重复id处理方式
+func (opts *SubmitOptions) getConflictPolicy() enums.WorkflowIdConflictPolicy {
+	if opts.WorkflowIDConflictPolicy != enums.WORKFLOW_ID_CONFLICT_POLICY_UNSPECIFIED {
+		return opts.WorkflowIDConflictPolicy
+	}
+	if opts.IdempotencyKey != "" {
+		return enums.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING
+	}
+	if opts.IdPolicy == IdPolicyExact || opts.IdPolicy == IdPolicyTemplate {
+		return enums.WORKFLOW_ID_CONFLICT_POLICY_FAIL
+	}
+	return opts.WorkflowIDConflictPolicy
+}
+
+// WorkflowAlreadyRunningError 相同id的流程已经在运行
+type WorkflowAlreadyRunningError struct {
+	WorkflowId string
+	RunId      string //正在运行的流程的runId
+	Err        error
+}
+
+func (e *WorkflowAlreadyRunningError) Error() string {
+	return fmt.Sprintf("workflow %s already running, runId: %s", e.WorkflowId, e.RunId)
+}
+
+func (e *WorkflowAlreadyRunningError) Unwrap() error {
+	return e.Err
+}
+
+// getWorkflowId 根据配置生成workflowId
+func (cw *commWork) getWorkflowId(opts *SubmitOptions) (string, error) {
+	//幂等key需要能通过 GetIdempotencyWorkflowId 直接查找到流程，其他生成方式会导致相同key不能去重
+	if opts.IdempotencyKey != "" && opts.IdPolicy != IdPolicyRandomSuffix {
+		return "", fmt.Errorf("idempotencyKey can only be used with IdPolicyRandomSuffix")
+	}
+	switch opts.IdPolicy {
+	case IdPolicyRandomSuffix:
+		if opts.IdempotencyKey != "" {
+			return GetIdempotencyWorkflowId(cw.queueName, opts.IdempotencyKey), nil
+		}
+	case IdPolicyExact:
+		if opts.WorkflowId == "" {
+			return "", fmt.Errorf("workflowId is empty")
+		}
+		return opts.WorkflowId, nil
+	case IdPolicyTemplate:
+		if opts.IdTemplate == "" {
+			return "", fmt.Errorf("idTemplate is empty")
+		}
+		return templates.NewTemplate(opts.IdTemplate).Replace(map[string]interface{}{
+			"queue":  cw.queueName,
+			"id":     opts.WorkflowId,
+			"random": utils.GetRandomString(5),
+			"uuid":   utils.NewUUID(),
+		})
+	}
+
+	workflowId := opts.WorkflowId
+	if workflowId == "" {
+		workflowId = utils.NewUUID()
+	} else {
+		workflowId = fmt.Sprintf("%s-%s", workflowId, utils.GetRandomString(5))
+	}
+	return fmt.Sprintf("%s/%s", cw.queueName, workflowId), nil
+}
+
+// getStartWorkflowOptions 转换为temporal的启动配置
+// 当前sdk的 StartWorkflowOptions 还不支持 WorkflowIDConflictPolicy 和 requestId，这里通过 WorkflowIDReusePolicy 和返回的错误来实现
+func (cw *commWork) getStartWorkflowOptions(opts *SubmitOptions) (client.StartWorkflowOptions, error) {
+	if opts.getConflictPolicy() == enums.WORKFLOW_ID_CONFLICT_POLICY_TERMINATE_EXISTING &&
+		opts.WorkflowIDReusePolicy != enums.WORKFLOW_ID_REUSE_POLICY_UNSPECIFIED &&
+		opts.WorkflowIDReusePolicy != enums.WORKFLOW_ID_REUSE_POLICY_TERMINATE_IF_RUNNING {
+		return client.StartWorkflowOptions{}, fmt.Errorf("WorkflowIDConflictPolicy %s conflicts with WorkflowIDReusePolicy %s",
+			opts.WorkflowIDConflictPolicy.String(), opts.WorkflowIDReusePolicy.String())
+	}
+	workflowId, err := cw.getWorkflowId(opts)
+	if err != nil {
+		return client.StartWorkflowOptions{}, err
+	}
+
+	workflowOptions := client.StartWorkflowOptions{
+		ID:                       workflowId,
+		TaskQueue:                cw.queueName,
+		WorkflowIDReusePolicy:    opts.WorkflowIDReusePolicy,
+		WorkflowExecutionTimeout: opts.WorkflowExecutionTimeout,
+		WorkflowRunTimeout:       opts.WorkflowRunTimeout,
+		Memo:                     opts.Memo,
+		TypedSearchAttributes:    opts.SearchAttributes,
+		StartDelay:               opts.StartDelay,
+		CronSchedule:             opts.CronSchedule,
+	}
+
+	if opts.IdempotencyKey != "" {
+		workflowOptions.Memo = make(map[string]interface{}, len(opts.Memo)+1)
+		for k, v := range opts.Memo {
+			workflowOptions.Memo[k] = v
+		}
+		workflowOptions.Memo[IdempotencyKeyMemoKey] = opts.IdempotencyKey
+		if workflowOptions.WorkflowIDReusePolicy == enums.WORKFLOW_ID_REUSE_POLICY_UNSPECIFIED {
+			workflowOptions.WorkflowIDReusePolicy = enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE
+		}
+	}
+
+	switch opts.getConflictPolicy() {
+	case enums.WORKFLOW_ID_CONFLICT_POLICY_FAIL, enums.WORKFLOW_ID_CONFLICT_POLICY_USE_EXISTING:
+		workflowOptions.WorkflowExecutionErrorWhenAlreadyStarted = true
+	case enums.WORKFLOW_ID_CONFLICT_POLICY_TERMINATE_EXISTING:
+		workflowOptions.WorkflowIDReusePolicy = enums.WORKFLOW_ID_REUSE_POLICY_TERMINATE_IF_RUNNING
+	}
+	return workflowOptions, nil
+}
+
+// getAlreadyStartedError 判断是否为流程已经存在的错误，返回正在运行的流程的runId
+func getAlreadyStartedError(err error) (*serviceerror.WorkflowExecutionAlreadyStarted, bool) {
+	var startedErr *serviceerror.WorkflowExecutionAlreadyStarted
+	if errors.As(err, &startedErr) {
+		return startedErr, true
+	}
+	return nil, false
+}
@@ -0,0 +1,121 @@
+package worker
+
+import (
+	"go.temporal.io/api/enums/v1"
+	"strings"
+	"testing"
+)
+
+func TestGetWorkflowId(t *testing.T) {
+	cw := &commWork{queueName: "queue"}
+	tests := []struct {
+		name   string
+		opts   *SubmitOptions
+		want   string
+		prefix string
+		hasErr bool
+	}{
+		{name: "random suffix", opts: &SubmitOptions{WorkflowId: "cd-1"}, prefix: "queue/cd-1-"},
+		{name: "random uuid", opts: &SubmitOptions{}, prefix: "queue/"},
+		{name: "idempotency key", opts: &SubmitOptions{WorkflowId: "cd-1", IdempotencyKey: "key-1"},
+			want: GetIdempotencyWorkflowId("queue", "key-1")},
+		{name: "exact", opts: &SubmitOptions{WorkflowId: "delete-cd/cd-1", IdPolicy: IdPolicyExact}, want: "delete-cd/cd-1"},
+		{name: "exact empty", opts: &SubmitOptions{IdPolicy: IdPolicyExact}, hasErr: true},
+		{name: "template", opts: &SubmitOptions{WorkflowId: "cd-1", IdPolicy: IdPolicyTemplate, IdTemplate: "{{queue}}-{{id}}"},
+			want: "queue-cd-1"},
+		{name: "template empty", opts: &SubmitOptions{IdPolicy: IdPolicyTemplate}, hasErr: true},
+		{name: "exact with idempotency key", opts: &SubmitOptions{WorkflowId: "cd-1", IdPolicy: IdPolicyExact,
+			IdempotencyKey: "key-1"}, hasErr: true},
+		{name: "template with idempotency key", opts: &SubmitOptions{WorkflowId: "cd-1", IdPolicy: IdPolicyTemplate,
+			IdTemplate: "{{id}}", IdempotencyKey: "key-1"}, hasErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cw.getWorkflowId(tt.opts)
+			if tt.hasErr {
+				if err == nil {
+					t.Fatalf("expected error, got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != "" && got != tt.want {
+				t.Fatalf("got %s, want %s", got, tt.want)
+			}
+			if tt.prefix != "" && (!strings.HasPrefix(got, tt.prefix) || len(got) <= len(tt.prefix)) {
+				t.Fatalf("got %s, want prefix %s", got, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestGetStartWorkflowOptionsWithIdempotencyKey(t *testing.T) {
+	cw := &commWork{queueName: "queue"}
+	memo := map[string]interface{}{"dslName": "delete-cd"}
+	opts := &SubmitOptions{WorkflowId: "cd-1", IdempotencyKey: "key-1", Memo: memo}
+
+	first, err := cw.getStartWorkflowOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := cw.getStartWorkflowOptions(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.ID != second.ID || first.ID != GetIdempotencyWorkflowId("queue", "key-1") {
+		t.Fatalf("idempotency workflowId not stable: %s %s", first.ID, second.ID)
+	}
+	if first.TaskQueue != "queue" || first.Memo[IdempotencyKeyMemoKey] != "key-1" || first.Memo["dslName"] != "delete-cd" {
+		t.Fatalf("unexpected options: %+v", first)
+	}
+	if _, ok := memo[IdempotencyKeyMemoKey]; ok {
+		t.Fatal("caller memo modified")
+	}
+	if first.WorkflowIDReusePolicy != enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE ||
+		!first.WorkflowExecutionErrorWhenAlreadyStarted {
+		t.Fatalf("unexpected policy: %+v", first)
+	}
+
+	if _, err = cw.getStartWorkflowOptions(&SubmitOptions{WorkflowId: "cd-1", IdPolicy: IdPolicyExact,
+		IdempotencyKey: "key-1"}); err == nil {
+		t.Fatal("expected error for idempotencyKey with IdPolicyExact")
+	}
+}
+
+func TestGetStartWorkflowOptionsTerminateExisting(t *testing.T) {
+	cw := &commWork{queueName: "queue"}
+	tests := []struct {
+		name        string
+		reusePolicy enums.WorkflowIdReusePolicy
+		hasErr      bool
+	}{
+		{name: "unspecified", reusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_UNSPECIFIED},
+		{name: "terminate if running", reusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_TERMINATE_IF_RUNNING},
+		{name: "reject duplicate", reusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_REJECT_DUPLICATE, hasErr: true},
+		{name: "allow duplicate", reusePolicy: enums.WORKFLOW_ID_REUSE_POLICY_ALLOW_DUPLICATE, hasErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cw.getStartWorkflowOptions(&SubmitOptions{
+				WorkflowId:               "cd-1",
+				IdPolicy:                 IdPolicyExact,
+				WorkflowIDReusePolicy:    tt.reusePolicy,
+				WorkflowIDConflictPolicy: enums.WORKFLOW_ID_CONFLICT_POLICY_TERMINATE_EXISTING,
+			})
+			if tt.hasErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.WorkflowIDReusePolicy != enums.WORKFLOW_ID_REUSE_POLICY_TERMINATE_IF_RUNNING {
+				t.Fatalf("unexpected policy: %s", got.WorkflowIDReusePolicy)
+			}
+		})
+	}
+}
@@ -5,7 +5,6 @@ import (
 	cmap "github.com/orcaman/concurrent-map"
 	"github.com/tianlin0/plat-lib/cond"
 	"github.com/tianlin0/plat-lib/conv"
-	"github.com/tianlin0/plat-lib/logs"
 	"github.com/tianlin0/plat-lib/templates"
 	"github.com/tianlin0/temporal/activity"
 	"regexp"
@@ -154,7 +153,6 @@ func (t *commWorkflow) ReplaceAllByBindings(args interface{}, bindings cmap.Conc
 	tmp := templates.NewTemplate(allParamStr)
 	allParamStrRet, err := tmp.Replace(argsMapList)
 	if err != nil {
-		logs.DefaultLogger().Error("ReplaceAllByBindings:", err)
 		return err
 	}
 	_ = conv.Unmarshal(allParamStrRet, args)
@@ -180,12 +178,9 @@ func (t *commWorkflow) GetOutputMap(defineMap map[string]interface{}, allOutMap
 	tmp := templates.NewTemplate(conv.String(args))
 	allParamStrRet, err := tmp.Replace(bindings)
 	if err != nil {
-		logs.DefaultLogger().Error("makeOutputMap:", err.Error())
 		return args, err
 	}
 
-	logs.DefaultLogger().Info(allParamStrRet)
-
 	err = conv.Unmarshal(allParamStrRet, &args)
 	return args, err
 }
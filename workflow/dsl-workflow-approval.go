@@ -0,0 +1,234 @@
+package workflow
+
+import (
+	"fmt"
+	cmap "github.com/orcaman/concurrent-map"
+	"github.com/tianlin0/plat-lib/cond"
+	"github.com/tianlin0/temporal/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"sort"
+	"time"
+)
+
+const (
+	ApprovalSignalName        = "dsl-approval"          //审批结果的signal名
+	ApprovalUpdateName        = "dsl-approval"          //审批结果的update名
+	PendingApprovalsQueryName = "dsl-pending-approvals" //查询等待中审批的query名
+	// PendingApprovalsSearchAttributeName 等待中的审批id列表，KeywordList类型，需要在temporal中预先注册
+	PendingApprovalsSearchAttributeName = "DslPendingApprovals"
+
+	ApprovalStatusApproved = "approved"
+	ApprovalStatusRejected = "rejected"
+	ApprovalStatusExpired  = "expired" //超时自动拒绝
+)
+
+var (
+	pendingApprovalsSearchAttribute = temporal.NewSearchAttributeKeyKeywordList(PendingApprovalsSearchAttributeName)
+)
+
+type (
+	// ApprovalDecision 审批结果，通过signal或者update传入
+	ApprovalDecision struct {
+		Id       string    `json:"id"`       //审批的id
+		Approved bool      `json:"approved"` //是否通过
+		Approver string    `json:"approver"` //审批人
+		Comment  string    `json:"comment"`  //审批意见
+		Time     time.Time `json:"time"`     //审批时间，由流程设置
+	}
+
+	// PendingApproval 等待中的审批
+	PendingApproval struct {
+		Id         string    `json:"id"`
+		Assignee   []string  `json:"assignee,omitempty"`
+		Message    string    `json:"message,omitempty"`
+		CreateTime time.Time `json:"createTime"`
+		ExpireTime time.Time `json:"expireTime,omitempty"` //为空表示不会超时
+	}
+)
+
+// Execute 等待审批结果，审批信息和结果会写入bindings中的 {{id.arguments.xxx}} 和 {{id.responses.xxx}}
+// 返回是否审批通过，超时未审批则自动拒绝
+func (a *ApprovalInvocation) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (cmap.ConcurrentMap, bool, error) {
+	if err := a.check(); err != nil {
+		return bindings, false, err
+	}
+	st := getDslState(ctx)
+	if st == nil {
+		return bindings, false, fmt.Errorf("approval %s must run in DslWorkflow", a.Id)
+	}
+
+	comm := New()
+	bindings, err := comm.ExtendToBindings(bindings, map[string]interface{}{
+		"assignee": a.Assignee,
+		"message":  a.Message,
+		"timeout":  a.Timeout,
+	}, a.Id, activity.Arguments)
+	if err != nil {
+		return bindings, false, err
+	}
+
+	timeout, _ := parseDuration(a.Timeout, 0)
+	now := workflow.Now(ctx)
+	pending := &PendingApproval{
+		Id:         a.Id,
+		Assignee:   a.Assignee,
+		Message:    a.Message,
+		CreateTime: now,
+	}
+	if timeout > 0 {
+		pending.ExpireTime = now.Add(timeout)
+	}
+
+	delete(st.approvalResults, a.Id)
+	if err = st.addPendingApproval(ctx, pending); err != nil {
+		return bindings, false, err
+	}
+
+	received := func() bool {
+		return st.approvalResults[a.Id] != nil
+	}
+	if timeout > 0 {
+		_, err = workflow.AwaitWithTimeout(ctx, timeout, received)
+	} else {
+		err = workflow.Await(ctx, received)
+	}
+	if errRemove := st.removePendingApproval(ctx, a.Id); errRemove != nil && err == nil {
+		err = errRemove
+	}
+	if err != nil {
+		return bindings, false, err
+	}
+
+	status := ApprovalStatusRejected
+	decision := st.approvalResults[a.Id]
+	if decision == nil {
+		status = ApprovalStatusExpired
+		decision = &ApprovalDecision{
+			Id:      a.Id,
+			Comment: "approval timeout",
+			Time:    workflow.Now(ctx),
+		}
+	} else if decision.Approved {
+		status = ApprovalStatusApproved
+	}
+	st.recordWaitMetrics(ctx, MetricWaitApproval, status, now)
+
+	bindings, err = comm.ExtendToBindings(bindings, map[string]interface{}{
+		"approved": decision.Approved,
+		"status":   status,
+		"approver": decision.Approver,
+		"comment":  decision.Comment,
+		"time":     decision.Time.Format(time.RFC3339),
+	}, a.Id, activity.Responses)
+	if err != nil {
+		return bindings, false, err
+	}
+	return bindings, decision.Approved, nil
+}
+
+// check 检查审批的配置
+func (a *ApprovalInvocation) check() error {
+	if a.Id == "" {
+		return fmt.Errorf("approval id is empty")
+	}
+	if _, err := parseDuration(a.Timeout, 0); err != nil {
+		return fmt.Errorf("approval %s timeout error: %s", a.Id, err.Error())
+	}
+	return nil
+}
+
+// registerApprovalHandlers 注册审批相关的query、update、signal处理方法
+func (st *dslState) registerApprovalHandlers(ctx workflow.Context) error {
+	err := workflow.SetQueryHandler(ctx, PendingApprovalsQueryName, func() ([]*PendingApproval, error) {
+		return st.getPendingApprovalList(), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, ApprovalUpdateName,
+		func(ctx workflow.Context, decision *ApprovalDecision) (*ApprovalDecision, error) {
+			return st.setApprovalDecision(ctx, decision), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(decision *ApprovalDecision) error {
+				return st.checkApprovalDecision(decision)
+			},
+		})
+	if err != nil {
+		return err
+	}
+
+	signalChan := workflow.GetSignalChannel(ctx, ApprovalSignalName)
+	workflow.Go(ctx, func(ctx workflow.Context) {
+		for {
+			decision := new(ApprovalDecision)
+			signalChan.Receive(ctx, decision)
+			if err := st.checkApprovalDecision(decision); err != nil {
+				getLogger(ctx).Warn("ignore approval signal:", err.Error())
+				continue
+			}
+			st.setApprovalDecision(ctx, decision)
+		}
+	})
+	return nil
+}
+
+// checkApprovalDecision 审批结果只能提交给等待中的审批，并且审批人需要在指定的列表中
+func (st *dslState) checkApprovalDecision(decision *ApprovalDecision) error {
+	if decision == nil || decision.Id == "" {
+		return fmt.Errorf("approval id is empty")
+	}
+	pending, ok := st.pendingApprovals[decision.Id]
+	if !ok {
+		return fmt.Errorf("approval %s is not pending", decision.Id)
+	}
+	if len(pending.Assignee) > 0 {
+		if ok, _ := cond.Contains(pending.Assignee, decision.Approver); !ok {
+			return fmt.Errorf("approver %s is not assigned to approval %s", decision.Approver, decision.Id)
+		}
+	}
+	return nil
+}
+
+func (st *dslState) setApprovalDecision(ctx workflow.Context, decision *ApprovalDecision) *ApprovalDecision {
+	decision.Time = workflow.Now(ctx)
+	st.approvalResults[decision.Id] = decision
+	return decision
+}
+
+func (st *dslState) addPendingApproval(ctx workflow.Context, pending *PendingApproval) error {
+	st.pendingApprovals[pending.Id] = pending
+	return st.upsertPendingApprovals(ctx)
+}
+
+func (st *dslState) removePendingApproval(ctx workflow.Context, id string) error {
+	delete(st.pendingApprovals, id)
+	return st.upsertPendingApprovals(ctx)
+}
+
+// upsertPendingApprovals 将等待中的审批id设置到 search attribute 中，方便通过可见性查询找到
+func (st *dslState) upsertPendingApprovals(ctx workflow.Context) error {
+	pendingList := st.getPendingApprovalList()
+	if len(pendingList) == 0 {
+		return workflow.UpsertTypedSearchAttributes(ctx, pendingApprovalsSearchAttribute.ValueUnset())
+	}
+	idList := make([]string, 0, len(pendingList))
+	for _, one := range pendingList {
+		idList = append(idList, one.Id)
+	}
+	return workflow.UpsertTypedSearchAttributes(ctx, pendingApprovalsSearchAttribute.ValueSet(idList))
+}
+
+// getPendingApprovalList 按id排序，保证结果是确定的
+func (st *dslState) getPendingApprovalList() []*PendingApproval {
+	pendingList := make([]*PendingApproval, 0, len(st.pendingApprovals))
+	for _, one := range st.pendingApprovals {
+		pendingList = append(pendingList, one)
+	}
+	sort.Slice(pendingList, func(i, j int) bool {
+		return pendingList[i].Id < pendingList[j].Id
+	})
+	return pendingList
+}
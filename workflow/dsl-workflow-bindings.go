@@ -0,0 +1,61 @@
+package workflow
+
+import (
+	"fmt"
+	cmap "github.com/orcaman/concurrent-map"
+	"github.com/tianlin0/plat-lib/cond"
+	"reflect"
+	"sort"
+)
+
+// copyBindings 复制一份bindings，每个id下的map也会复制一层，
+// 这样分支里通过 ExtendToBindings 写入时不会影响到原始的bindings
+func copyBindings(bindings cmap.ConcurrentMap) cmap.ConcurrentMap {
+	newBindings := cmap.New()
+	if bindings == nil {
+		return newBindings
+	}
+	for key, val := range bindings.Items() {
+		if valMap, ok := val.(map[string]interface{}); ok {
+			newMap := make(map[string]interface{}, len(valMap))
+			for k, v := range valMap {
+				newMap[k] = v
+			}
+			val = newMap
+		}
+		newBindings.Set(key, val)
+	}
+	return newBindings
+}
+
+// mergeBranchBindings 按分支顺序将并行分支的结果合并回bindings
+// base 为分支开始前的bindings副本，branchIds 为每个分支自己拥有的id列表
+// 分支只能写自己的id，如果写了其它分支独有的id，则返回错误
+func mergeBranchBindings(bindings cmap.ConcurrentMap, base cmap.ConcurrentMap,
+	branchBindings []cmap.ConcurrentMap, branchIds [][]string) error {
+	for i, oneBindings := range branchBindings {
+		if oneBindings == nil {
+			continue
+		}
+		keys := oneBindings.Keys()
+		sort.Strings(keys)
+		for _, key := range keys {
+			val, _ := oneBindings.Get(key)
+			if baseVal, ok := base.Get(key); ok && reflect.DeepEqual(baseVal, val) {
+				continue
+			}
+			if ok, _ := cond.Contains(branchIds[i], key); !ok {
+				for j, ids := range branchIds {
+					if j == i {
+						continue
+					}
+					if ok, _ := cond.Contains(ids, key); ok {
+						return fmt.Errorf("parallel branch %d writes to id %s of branch %d", i, key, j)
+					}
+				}
+			}
+			bindings.Set(key, val)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package workflow
+
+import (
+	"fmt"
+	"github.com/tianlin0/plat-lib/cond"
+	"github.com/tianlin0/plat-lib/conv"
+	"go.temporal.io/sdk/workflow"
+	"sort"
+)
+
+const (
+	PauseUpdateName       = "dsl-pause"         //暂停流程，正在执行的activity会执行完，后续的activity等待恢复
+	ResumeUpdateName      = "dsl-resume"        //恢复暂停的流程
+	SkipStepUpdateName    = "dsl-skip-step"     //跳过某个activity，使用设置的返回值
+	RetryStepUpdateName   = "dsl-retry-step"    //重试某个执行失败的activity
+	ControlStateQueryName = "dsl-control-state" //查询暂停、失败等待等状态
+)
+
+type (
+	// StepDecision 对某个activity的人工处理
+	StepDecision struct {
+		ActivityId string                 `json:"activityId"`          //DSL中activity的id
+		Responses  map[string]interface{} `json:"responses,omitempty"` //跳过时作为activity的返回值
+		Operator   string                 `json:"operator,omitempty"`  //操作人
+	}
+
+	// ControlState 流程的控制状态
+	ControlState struct {
+		Paused      bool              `json:"paused"`
+		FailedSteps map[string]string `json:"failedSteps,omitempty"` //等待人工处理的失败activity，以及失败原因
+		SkipSteps   []string          `json:"skipSteps,omitempty"`   //设置了跳过，还未执行到的activity
+	}
+)
+
+// registerControlHandlers 注册暂停、恢复、跳过、重试的处理方法
+func (st *dslState) registerControlHandlers(ctx workflow.Context) error {
+	err := workflow.SetQueryHandler(ctx, ControlStateQueryName, func() (*ControlState, error) {
+		return st.getControlState(), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = workflow.SetUpdateHandler(ctx, PauseUpdateName, func(ctx workflow.Context, operator string) (*ControlState, error) {
+		getLogger(ctx).Info("DslWorkflow paused by:", operator)
+		st.paused = true
+		return st.getControlState(), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = workflow.SetUpdateHandler(ctx, ResumeUpdateName, func(ctx workflow.Context, operator string) (*ControlState, error) {
+		getLogger(ctx).Info("DslWorkflow resumed by:", operator)
+		st.paused = false
+		return st.getControlState(), nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = workflow.SetUpdateHandlerWithOptions(ctx, SkipStepUpdateName,
+		func(ctx workflow.Context, decision *StepDecision) (*ControlState, error) {
+			getLogger(ctx).Info("DslWorkflow skip step:", conv.String(decision))
+			responses := decision.Responses
+			if responses == nil {
+				responses = map[string]interface{}{}
+			}
+			st.skipSteps[decision.ActivityId] = responses
+			return st.getControlState(), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(decision *StepDecision) error {
+				if err := st.checkStepDecision(decision); err != nil {
+					return err
+				}
+				if st.completedSteps[decision.ActivityId] {
+					return fmt.Errorf("step %s has completed", decision.ActivityId)
+				}
+				return nil
+			},
+		})
+	if err != nil {
+		return err
+	}
+
+	return workflow.SetUpdateHandlerWithOptions(ctx, RetryStepUpdateName,
+		func(ctx workflow.Context, decision *StepDecision) (*ControlState, error) {
+			getLogger(ctx).Info("DslWorkflow retry step:", conv.String(decision))
+			st.retrySteps[decision.ActivityId] = true
+			return st.getControlState(), nil
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(decision *StepDecision) error {
+				if err := st.checkStepDecision(decision); err != nil {
+					return err
+				}
+				if _, ok := st.failedSteps[decision.ActivityId]; !ok {
+					return fmt.Errorf("step %s is not waiting for retry", decision.ActivityId)
+				}
+				return nil
+			},
+		})
+}
+
+func (st *dslState) checkStepDecision(decision *StepDecision) error {
+	if decision == nil || decision.ActivityId == "" {
+		return fmt.Errorf("activityId is empty")
+	}
+	if ok, _ := cond.Contains(st.stepIds, decision.ActivityId); !ok {
+		return fmt.Errorf("step %s not found", decision.ActivityId)
+	}
+	return nil
+}
+
+// waitResumed 流程暂停时等待恢复
+func (st *dslState) waitResumed(ctx workflow.Context) error {
+	if !st.paused {
+		return nil
+	}
+	startTime := workflow.Now(ctx)
+	err := workflow.Await(ctx, func() bool {
+		return !st.paused
+	})
+	st.recordWaitMetrics(ctx, MetricWaitPause, "", startTime)
+	return err
+}
+
+// popSkipStep 获取并移除跳过activity时使用的返回值，没有设置跳过时返回nil
+func (st *dslState) popSkipStep(id string) map[string]interface{} {
+	responses, ok := st.skipSteps[id]
+	if !ok {
+		return nil
+	}
+	delete(st.skipSteps, id)
+	return responses
+}
+
+// waitStepDecision activity执行失败后，等待人工重试或者跳过
+func (st *dslState) waitStepDecision(ctx workflow.Context, id string, stepErr error) error {
+	getLogger(ctx, id).Error("DslWorkflow step failed, wait for retry or skip:", id, stepErr.Error())
+	st.failedSteps[id] = stepErr.Error()
+	startTime := workflow.Now(ctx)
+	err := workflow.Await(ctx, func() bool {
+		_, skip := st.skipSteps[id]
+		return st.retrySteps[id] || skip
+	})
+	outcome := "retry"
+	if _, skip := st.skipSteps[id]; skip {
+		outcome = MetricOutcomeSkipped
+	}
+	st.recordWaitMetrics(ctx, MetricWaitStepDecision, outcome, startTime)
+	delete(st.failedSteps, id)
+	delete(st.retrySteps, id)
+	return err
+}
+
+func (st *dslState) getControlState() *ControlState {
+	state := &ControlState{
+		Paused:      st.paused,
+		FailedSteps: make(map[string]string),
+		SkipSteps:   make([]string, 0),
+	}
+	for id, msg := range st.failedSteps {
+		state.FailedSteps[id] = msg
+	}
+	for id := range st.skipSteps {
+		state.SkipSteps = append(state.SkipSteps, id)
+	}
+	sort.Strings(state.SkipSteps)
+	return state
+}
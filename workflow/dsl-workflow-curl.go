@@ -1,16 +1,14 @@
 package workflow
 
 import (
-	"context"
 	"fmt"
 	cmap "github.com/orcaman/concurrent-map"
 	"github.com/tianlin0/plat-lib/cond"
 	"github.com/tianlin0/plat-lib/conv"
-	"github.com/tianlin0/plat-lib/goroutines"
-	"github.com/tianlin0/plat-lib/logs"
 	"github.com/tianlin0/temporal/activity"
 	"github.com/tidwall/gjson"
 	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
 	"strings"
 	"time"
@@ -18,10 +16,16 @@ import (
 
 type (
 	DslWorkflow struct {
+		Name       string                 `json:"name,omitempty"`       //流程定义的名字，写入memo的 dslName 中，用于列表展示
 		Variables  map[string]interface{} `json:"variables,omitempty"`  //传入的所有变量参数，包括可以设置某一步的参数
 		Root       Statement              `json:"root,omitempty"`       //启动的根目录
 		Activities []*OneActivity         `json:"activities,omitempty"` //公共的activity资源，用于公共执行的部分,比如公共打日志
 		Responses  map[string]interface{} `json:"responses,omitempty"`  //请求返回的内容
+
+		WaitOnFailure    bool                     `json:"waitOnFailure,omitempty"`    //activity执行失败时，等待人工重试或者跳过，而不是直接失败
+		SearchAttributes []*SearchAttributeDefine `json:"searchAttributes,omitempty"` //需要写入search attribute的变量或者返回值，用于可见性查询
+		Seeds            map[string]*StepSeed     `json:"seeds,omitempty"`            //重新执行时，之前已经执行成功的activity，key为activity id
+		Notifications    []*Notification          `json:"notifications,omitempty"`    //流程结束时的通知，成功和失败都会发送
 	}
 
 	OneActivity struct {
@@ -38,6 +42,8 @@ type (
 	Statement struct {
 		Control  *Control            `json:"control,omitempty"`
 		Activity *ActivityInvocation `json:"activity,omitempty"`
+		Poll     *PollInvocation     `json:"poll,omitempty"`     //轮询执行某个activity，直到条件满足
+		Approval *ApprovalInvocation `json:"approval,omitempty"` //人工审批，在activity之前执行，审批通过后才继续执行
 		Parallel Parallel            `json:"parallel,omitempty"`
 		Sequence Sequence            `json:"sequence,omitempty"`
 	}
@@ -48,6 +54,25 @@ type (
 		Arguments map[string]interface{} `json:"arguments,omitempty"` //需要的参数列表,string为传进来的key
 		Responses map[string]interface{} `json:"responses,omitempty"` //返回的字段列表,string为返回的key，可以自定义添加内容
 	}
+	// PollInvocation 按间隔重复执行activity，直到 Until 条件满足，等待期间使用workflow的定时器，不占用activity
+	PollInvocation struct {
+		Activity           *ActivityInvocation `json:"activity,omitempty"`           //每次轮询执行的activity
+		Until              string              `json:"until,omitempty"`              //结束轮询的条件，可以使用activity的返回值
+		Interval           string              `json:"interval,omitempty"`           //轮询间隔，如：10s，默认10s
+		BackoffCoefficient float64             `json:"backoffCoefficient,omitempty"` //间隔的增长系数，默认为1，不增长
+		MaxInterval        string              `json:"maxInterval,omitempty"`        //最大的轮询间隔
+		MaxDuration        string              `json:"maxDuration,omitempty"`        //最长的轮询时间，超过则报错
+		MaxAttempts        int                 `json:"maxAttempts,omitempty"`        //最多执行次数，超过则报错
+	}
+	// ApprovalInvocation 人工审批，等待审批结果的signal或者update
+	ApprovalInvocation struct {
+		Id        string   `json:"id,omitempty"`        //审批的id，结果写入 {{id.responses.approved}} 等
+		Assignee  []string `json:"assignee,omitempty"`  //可以审批的人，为空则不限制
+		Message   string   `json:"message,omitempty"`   //审批说明
+		Timeout   string   `json:"timeout,omitempty"`   //超时时间，如：24h，超时后自动拒绝，为空则一直等待
+		OnApprove Sequence `json:"onApprove,omitempty"` //审批通过后执行的内容
+		OnReject  Sequence `json:"onReject,omitempty"`  //审批拒绝后执行的内容，执行完不再继续当前语句；为空则直接报错
+	}
 	Sequence []*Statement
 	Parallel []*Statement
 
@@ -63,6 +88,30 @@ const (
 	exitLifecycleEvent = "exit"
 )
 
+// getActivityList 获取语句中直接执行的activity，包括轮询的activity
+func (b *Statement) getActivityList() []*ActivityInvocation {
+	actList := make([]*ActivityInvocation, 0)
+	if b.Activity != nil {
+		actList = append(actList, b.Activity)
+	}
+	if b.Poll != nil && b.Poll.Activity != nil {
+		actList = append(actList, b.Poll.Activity)
+	}
+	return actList
+}
+
+// getChildStatementList 获取语句下所有的子语句
+func (b *Statement) getChildStatementList() []*Statement {
+	childList := make([]*Statement, 0)
+	if b.Approval != nil {
+		childList = append(childList, b.Approval.OnApprove...)
+		childList = append(childList, b.Approval.OnReject...)
+	}
+	childList = append(childList, b.Parallel...)
+	childList = append(childList, b.Sequence...)
+	return childList
+}
+
 func (lchs LifecycleHooks) getExitHook() *ActivityInvocation {
 	hook, ok := lchs[exitLifecycleEvent]
 	if ok {
@@ -81,7 +130,6 @@ func (t *DslWorkflow) SetVariablesToAll(bindings cmap.ConcurrentMap) (*DslWorkfl
 	comm := New()
 	err := comm.ReplaceAllByBindings(t, bindings)
 	if err != nil {
-		logs.DefaultLogger().Error("makeInputMap:", err)
 		return t, err
 	}
 	newDsl := new(DslWorkflow)
@@ -104,20 +152,10 @@ func (t *DslWorkflow) getAllWorkflowIdList(b *Statement, a []*OneActivity) []str
 
 func (t *DslWorkflow) getOneActivityList(b *Statement) []*ActivityInvocation {
 	newList := make([]*ActivityInvocation, 0)
-	if b.Activity != nil {
-		newList = append(newList, b.Activity)
-	}
-	if b.Sequence != nil && len(b.Sequence) > 0 {
-		for _, one := range b.Sequence {
-			sList := t.getOneActivityList(one)
-			newList = append(newList, sList...)
-		}
-	}
-	if b.Parallel != nil && len(b.Parallel) > 0 {
-		for _, one := range b.Parallel {
-			sList := t.getOneActivityList(one)
-			newList = append(newList, sList...)
-		}
+	newList = append(newList, b.getActivityList()...)
+	for _, one := range b.getChildStatementList() {
+		sList := t.getOneActivityList(one)
+		newList = append(newList, sList...)
 	}
 	return newList
 }
@@ -141,22 +179,17 @@ func (t *DslWorkflow) GetAllActivityList() []*ActivityInvocation {
 }
 
 func (t *DslWorkflow) getRootWorkflowIdList(b *Statement, idList *[]string) {
-	if b.Activity != nil {
-		if b.Activity.Id != "" {
-			*idList = append(*idList, b.Activity.Id)
-		}
+	if b.Approval != nil && b.Approval.Id != "" {
+		*idList = append(*idList, b.Approval.Id)
 	}
-
-	if b.Sequence != nil && len(b.Sequence) > 0 {
-		for i, _ := range b.Sequence {
-			t.getRootWorkflowIdList(b.Sequence[i], idList)
+	for _, one := range b.getActivityList() {
+		if one.Id != "" {
+			*idList = append(*idList, one.Id)
 		}
 	}
 
-	if b.Parallel != nil && len(b.Parallel) > 0 {
-		for i, _ := range b.Parallel {
-			t.getRootWorkflowIdList(b.Parallel[i], idList)
-		}
+	for _, one := range b.getChildStatementList() {
+		t.getRootWorkflowIdList(one, idList)
 	}
 }
 
@@ -196,28 +229,20 @@ func (t *DslWorkflow) setCommVariablesToArgument(b *Statement, variable map[stri
 		allWorkflowId = []string{}
 	}
 
-	if b.Activity != nil {
-		if b.Activity.Arguments == nil {
-			b.Activity.Arguments = map[string]interface{}{}
+	for _, one := range b.getActivityList() {
+		if one.Arguments == nil {
+			one.Arguments = map[string]interface{}{}
 		}
 		arguments := cmap.New()
-		for k, v := range b.Activity.Arguments {
+		for k, v := range one.Arguments {
 			arguments.Set(k, v)
 		}
 		cm := New()
-		b.Activity.Arguments = cm.GetInputMap(b.Activity.Id, arguments, variable, allWorkflowId)
-	}
-
-	if b.Sequence != nil && len(b.Sequence) > 0 {
-		for i, _ := range b.Sequence {
-			t.setCommVariablesToArgument(b.Sequence[i], variable, allWorkflowId)
-		}
+		one.Arguments = cm.GetInputMap(one.Id, arguments, variable, allWorkflowId)
 	}
 
-	if b.Parallel != nil && len(b.Parallel) > 0 {
-		for i, _ := range b.Parallel {
-			t.setCommVariablesToArgument(b.Parallel[i], variable, allWorkflowId)
-		}
+	for _, one := range b.getChildStatementList() {
+		t.setCommVariablesToArgument(one, variable, allWorkflowId)
 	}
 }
 
@@ -225,28 +250,20 @@ func (t *DslWorkflow) setAllActivitiesToRoot(a []*OneActivity, b *Statement) {
 	if a == nil || len(a) == 0 {
 		return
 	}
-	if b.Activity != nil {
-		if b.Activity.Id != "" {
+	for _, act := range b.getActivityList() {
+		if act.Id != "" {
 			for _, one := range a {
-				if one.Activity.Id == b.Activity.Id {
-					if b.Activity.Template == "" {
-						t.copyOneActivity(one.Activity, b.Activity)
+				if one.Activity.Id == act.Id {
+					if act.Template == "" {
+						t.copyOneActivity(one.Activity, act)
 					}
 				}
 			}
 		}
 	}
 
-	if b.Sequence != nil && len(b.Sequence) > 0 {
-		for i, _ := range b.Sequence {
-			t.setAllActivitiesToRoot(a, b.Sequence[i])
-		}
-	}
-
-	if b.Parallel != nil && len(b.Parallel) > 0 {
-		for i, _ := range b.Parallel {
-			t.setAllActivitiesToRoot(a, b.Parallel[i])
-		}
+	for _, one := range b.getChildStatementList() {
+		t.setAllActivitiesToRoot(a, one)
 	}
 }
 
@@ -280,10 +297,7 @@ func (t *DslWorkflow) copyOneActivity(source *ActivityInvocation, to *ActivityIn
 func (b *Statement) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (cmap.ConcurrentMap, error) {
 	var err error
 
-	logger := logs.DefaultLogger()
-	if newCtx, ok := ctx.(context.Context); ok {
-		logger = logs.CtxLogger(newCtx)
-	}
+	logger := getLogger(ctx)
 
 	//执行activity前，首先进行条件判断，有条件未满足，则直接报错
 	if b.Control != nil {
@@ -323,6 +337,33 @@ func (b *Statement) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (
 		}
 	}
 
+	//需要人工审批的情况，审批拒绝后不再继续执行当前语句
+	if b.Approval != nil {
+		var approved bool
+		bindings, approved, err = b.Approval.Execute(ctx, bindings)
+		if err != nil {
+			logger.Error("Statement.execute approval error:", conv.String(err.Error()))
+			return bindings, err
+		}
+		if st := getDslState(ctx); st != nil {
+			if err = st.upsertSearchAttributes(ctx, bindings); err != nil {
+				return bindings, err
+			}
+		}
+		if !approved {
+			if len(b.Approval.OnReject) == 0 {
+				return bindings, fmt.Errorf("approval %s rejected", b.Approval.Id)
+			}
+			return b.Approval.OnReject.Execute(ctx, bindings)
+		}
+		if len(b.Approval.OnApprove) > 0 {
+			bindings, err = b.Approval.OnApprove.Execute(ctx, bindings)
+			if err != nil {
+				return bindings, err
+			}
+		}
+	}
+
 	// 遇到错误，是否直接退出
 	isExit := false
 	//是否需要直接返回
@@ -349,6 +390,14 @@ func (b *Statement) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (
 		}
 	}
 
+	if b.Poll != nil {
+		bindings, err = b.Poll.Execute(ctx, bindings)
+		if err != nil {
+			logger.Error("Statement.execute poll error:", conv.String(err.Error()))
+			return bindings, err
+		}
+	}
+
 	if isExit && b.Activity != nil {
 		//如果执行没有返回正确的值，则表示没有执行成功
 		bindingsJson := conv.String(bindings)
@@ -375,6 +424,22 @@ func (b *Statement) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (
 			comm := New()
 			childWorkflow := new(DslWorkflow)
 
+			childMemo := map[string]interface{}{}
+			childSearchAttributes := temporal.SearchAttributes{}
+			if st := getDslState(ctx); st != nil {
+				childWorkflow.Name = st.dslName
+				childWorkflow.WaitOnFailure = st.waitOnFailure
+				childWorkflow.Seeds = st.seeds
+				childWorkflow.SearchAttributes = st.searchAttributes
+				childSearchAttributes = st.getStartSearchAttributes()
+				if st.live != nil {
+					childWorkflow.Notifications = st.live.Notifications
+				}
+				if st.dslName != "" {
+					childMemo[DslNameMemoKey] = st.dslName
+				}
+			}
+
 			childWorkflow.Root = Statement{
 				Control:  nil,
 				Activity: nil,
@@ -392,6 +457,9 @@ func (b *Statement) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (
 				ParentClosePolicy: enums.PARENT_CLOSE_POLICY_ABANDON,
 				Namespace:         workInfo.Namespace,
 				TaskQueue:         workInfo.TaskQueueName,
+				Memo:              childMemo,
+				//已经取到值的search attribute在启动时设置，之后的activity执行完后由子流程更新
+				TypedSearchAttributes: childSearchAttributes,
 				//WorkflowExecutionTimeout: time.Minute,
 				//WorkflowTaskTimeout:      time.Second * 10,
 				//WorkflowID:               "", //子流程的workflowID
@@ -404,14 +472,19 @@ func (b *Statement) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (
 
 			childWorkflowIns := workflow.ExecuteChildWorkflow(childCtx, workInfo.WorkflowType.Name, childOption, childWorkflow, childWorkflow.Variables)
 
-			logger.Debug("ExecuteChildWorkflow 1:", conv.String(time.Now()))
-
-			goroutines.GoAsyncHandler(func(params ...interface{}) {
-				err = childWorkflowIns.Get(childCtx, nil)
-				logger.Error("ExecuteChildWorkflow childCtx error:", err)
-				logger.Debug("ExecuteChildWorkflow 2:", conv.String(time.Now()))
-			}, nil)
-
+			//之前启动的流程启动子流程后直接返回，结束通知和指标仍由本流程记录
+			if workflow.GetVersion(ctx, continueChildChangeId, workflow.DefaultVersion, 1) == workflow.DefaultVersion {
+				return bindings, nil
+			}
+			//等待子流程启动后再返回，后续的步骤在子流程中执行，结束通知也由子流程发送
+			if err = childWorkflowIns.GetChildWorkflowExecution().Get(ctx, nil); err != nil {
+				logger.Error("ExecuteChildWorkflow start error:", err)
+				return bindings, err
+			}
+			if st := getDslState(ctx); st != nil {
+				st.continued = true
+			}
+			logger.Debug("ExecuteChildWorkflow started:", conv.String(workflow.Now(ctx)))
 		}
 
 		return bindings, nil
@@ -454,7 +527,38 @@ func (b *Statement) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (
 	return bindings, nil
 }
 
+// Execute 执行activity，执行前如果流程被暂停则等待恢复，如果被设置为跳过则直接使用设置的返回值
+// 流程设置了 WaitOnFailure 时，执行失败后会等待人工重试或者跳过，而不是直接失败
 func (a *ActivityInvocation) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (cmap.ConcurrentMap, error) {
+	st := getDslState(ctx)
+	if st == nil {
+		return a.execute(ctx, bindings, nil)
+	}
+
+	for {
+		if err := st.waitResumed(ctx); err != nil {
+			return bindings, err
+		}
+
+		var err error
+		st.startedSteps[a.Id] = true
+		bindings, err = a.execute(ctx, bindings, st.popSkipStep(a.Id))
+		if err == nil {
+			st.completedSteps[a.Id] = true
+			return bindings, st.upsertSearchAttributes(ctx, bindings)
+		}
+		if !st.waitOnFailure || a.Id == "" {
+			return bindings, err
+		}
+		if err = st.waitStepDecision(ctx, a.Id, err); err != nil {
+			return bindings, err
+		}
+	}
+}
+
+// execute 执行activity，fakeOutput 不为nil时不真正执行，直接作为activity的返回值
+func (a *ActivityInvocation) execute(ctx workflow.Context, bindings cmap.ConcurrentMap,
+	fakeOutput map[string]interface{}) (cmap.ConcurrentMap, error) {
 	workflowInfo := workflow.GetInfo(ctx)
 
 	taskQueueName := workflowInfo.TaskQueueName
@@ -466,17 +570,23 @@ func (a *ActivityInvocation) Execute(ctx workflow.Context, bindings cmap.Concurr
 		return bindings, fmt.Errorf("%s/%s activity not register", taskQueueName, templateName)
 	}
 
-	//获取参数
-	inputParam, err := a.getActivityInputMap(a.Arguments, bindings)
-	if err != nil {
-		return bindings, err
-	}
-
 	comm := New()
-	//input 不能有没有取到的值，否则直接报错
-	err = comm.CheckArguments(a.Id, inputParam)
-	if err != nil {
-		return bindings, err
+	var err error
+	//重新执行时，跳过的activity使用原流程中记录的参数
+	inputParam := getSeedArguments(ctx, a.Id, fakeOutput)
+	if inputParam == nil {
+		//获取参数
+		inputParam, err = a.getActivityInputMap(a.Arguments, bindings)
+		if err != nil {
+			getLogger(ctx, a.Id).Error("getActivityInputMap:", err)
+			return bindings, err
+		}
+
+		//input 不能有没有取到的值，否则直接报错
+		err = comm.CheckArguments(a.Id, inputParam)
+		if err != nil {
+			return bindings, err
+		}
 	}
 
 	//将参数合并到bindings中
@@ -490,20 +600,34 @@ func (a *ActivityInvocation) Execute(ctx workflow.Context, bindings cmap.Concurr
 		return bindings, errRet
 	}
 
-	logger := logs.DefaultLogger()
-	if newCtx, ok := ctx.(context.Context); ok {
-		logger = logs.CtxLogger(newCtx)
-	}
+	logger := getLogger(ctx, a.Id)
 
 	logger.Info(fmt.Sprintf("%s %s %s param: %s",
 		taskQueueName, templateName, a.Id, conv.String(inputParam)))
 
-	err = workflow.ExecuteActivity(ctx,
-		ac.GetActivityName(taskQueueName, templateName), inputParam).Get(ctx, oneRet)
+	if fakeOutput != nil {
+		logger.Info(fmt.Sprintf("%s %s %s skipped with responses: %s",
+			taskQueueName, templateName, a.Id, conv.String(fakeOutput)))
+		oneRet = fakeOutput
+		recordStepMetrics(ctx, templateName, MetricOutcomeSkipped, time.Time{})
+	} else {
+		actCtx := ctx
+		if st := getDslState(ctx); st != nil {
+			if activityId := st.getActivityId(a.Id); activityId != "" {
+				actOption := workflow.GetActivityOptions(ctx)
+				actOption.ActivityID = activityId
+				actCtx = workflow.WithActivityOptions(ctx, actOption)
+			}
+		}
+		startTime := workflow.Now(ctx)
+		err = workflow.ExecuteActivity(actCtx,
+			ac.GetActivityName(taskQueueName, templateName), inputParam).Get(ctx, oneRet)
+		recordStepMetrics(ctx, templateName, getMetricOutcome(err), startTime)
 
-	if err != nil {
-		//如果是异步，这里就不用返回错误
-		return bindings, activity.New().GetErrorByTemporalError(err)
+		if err != nil {
+			//如果是异步，这里就不用返回错误
+			return bindings, activity.New().GetErrorByTemporalError(err)
+		}
 	}
 
 	outputResult := make(map[string]interface{})
@@ -517,6 +641,7 @@ func (a *ActivityInvocation) Execute(ctx workflow.Context, bindings cmap.Concurr
 	if len(a.Responses) > 0 {
 		outputResult, err = comm.GetOutputMap(a.Responses, outputResult, bindings)
 		if err != nil {
+			logger.Error("GetOutputMap:", err)
 			return bindings, err
 		}
 	}
@@ -542,8 +667,14 @@ func (p Parallel) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (cm
 	selector := workflow.NewSelector(ctx)
 	var activityErr error
 
-	for _, s := range p {
-		f := p.executeAsync(s, childCtx, bindings)
+	//每个分支使用独立的bindings副本，互相看不到对方写入的内容，全部结束后按分支顺序合并
+	base := copyBindings(bindings)
+	branchBindings := make([]cmap.ConcurrentMap, len(p))
+	branchIds := make([][]string, len(p))
+
+	for i, s := range p {
+		branchIds[i] = p.getBranchIdList(s)
+		f := p.executeAsync(s, childCtx, copyBindings(base), &branchBindings[i])
 		selector.AddFuture(f, func(f workflow.Future) {
 			err := f.Get(ctx, nil)
 			if err != nil {
@@ -556,22 +687,38 @@ func (p Parallel) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (cm
 		selector.Select(ctx) // this will wait for one branch
 		if activityErr != nil {
 			cancelHandler()
+			//分支之间写入冲突时和分支的错误一起返回，避免冲突被掩盖
+			if err := mergeBranchBindings(bindings, base, branchBindings, branchIds); err != nil {
+				return bindings, fmt.Errorf("%w; %s", activityErr, err.Error())
+			}
 			return bindings, activityErr
 		}
 	}
 
+	if err := mergeBranchBindings(bindings, base, branchBindings, branchIds); err != nil {
+		return bindings, err
+	}
 	return bindings, nil
 }
 
-func (p Parallel) executeAsync(exe executable, ctx workflow.Context, bindings cmap.ConcurrentMap) workflow.Future {
+func (p Parallel) executeAsync(exe executable, ctx workflow.Context, bindings cmap.ConcurrentMap,
+	ret *cmap.ConcurrentMap) workflow.Future {
 	future, settable := workflow.NewFuture(ctx)
 	workflow.Go(ctx, func(ctx workflow.Context) {
 		val, err := exe.Execute(ctx, bindings)
-		settable.Set(val, err)
+		*ret = val
+		settable.Set(nil, err)
 	})
 	return future
 }
 
+// getBranchIdList 获取某个分支下所有的activity id
+func (p Parallel) getBranchIdList(s *Statement) []string {
+	idList := make([]string, 0)
+	new(DslWorkflow).getRootWorkflowIdList(s, &idList)
+	return idList
+}
+
 func (a *ActivityInvocation) getActivityInputMap(currArguments map[string]interface{}, arguments cmap.ConcurrentMap) (
 	map[string]interface{}, error) {
 	args := make(map[string]interface{})
@@ -586,17 +733,13 @@ func (a *ActivityInvocation) getActivityInputMap(currArguments map[string]interf
 	comm := New()
 	err := comm.ReplaceAllByBindings(&args, arguments)
 	if err != nil {
-		logs.DefaultLogger().Error("makeInputMap:", err)
 		return args, err
 	}
 	return args, nil
 }
 
 func (s Sequence) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (cmap.ConcurrentMap, error) {
-	logger := logs.DefaultLogger()
-	if newCtx, ok := ctx.(context.Context); ok {
-		logger = logs.CtxLogger(newCtx)
-	}
+	logger := getLogger(ctx)
 
 	var err error
 	for _, a := range s {
@@ -0,0 +1,22 @@
+package workflow
+
+import (
+	"github.com/tianlin0/plat-lib/logs"
+	"github.com/tianlin0/temporal/activity"
+	"github.com/tianlin0/temporal/propagator"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+)
+
+// getLogger 流程中使用的日志，通过 workflow.GetLogger 打印，重放时不会重复打印
+// temporal的日志已经带上流程id和run id，这里增加流程定义的名字，activityId 不为空时增加 activity id
+func getLogger(ctx workflow.Context, activityId ...string) logs.ILogger {
+	logger := workflow.GetLogger(ctx)
+	if st := getDslState(ctx); st != nil && st.dslName != "" {
+		logger = log.With(logger, activity.LogKeyDslName, st.dslName)
+	}
+	if len(activityId) > 0 && activityId[0] != "" {
+		logger = log.With(logger, activity.LogKeyActivityId, activityId[0])
+	}
+	return activity.NewLogger(logger, propagator.GetLogId(ctx))
+}
@@ -0,0 +1,164 @@
+package workflow
+
+import (
+	"context"
+	"github.com/tianlin0/temporal/activity"
+	act "go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/workflow"
+	"strings"
+	"time"
+)
+
+const (
+	MetricStepDuration     = "dsl_step_duration"     //activity从调度到结束的耗时，包含重试
+	MetricStepTotal        = "dsl_step_total"        //activity执行的次数，按 outcome 区分结果
+	MetricStepRetries      = "dsl_step_retries"      //activity重试的次数，在worker上记录
+	MetricWorkflowDuration = "dsl_workflow_duration" //流程从启动到结束的耗时
+	MetricWorkflowTotal    = "dsl_workflow_total"    //流程结束的次数，按 outcome 区分结果
+	MetricWaitDuration     = "dsl_wait_duration"     //等待审批、暂停恢复、失败后人工处理的耗时，按 kind 区分
+
+	MetricTagTaskQueue = "task_queue"
+	MetricTagTemplate  = "template"
+	MetricTagDslName   = "dsl_name"
+	MetricTagOutcome   = "outcome"
+	MetricTagWaitKind  = "kind"
+
+	MetricOutcomeCompleted = "completed"
+	MetricOutcomeFailed    = "failed"
+	MetricOutcomeSkipped   = "skipped" //重新执行或者人工跳过，没有真正执行
+
+	MetricWaitApproval     = "approval"
+	MetricWaitPause        = "pause"
+	MetricWaitStepDecision = "stepDecision"
+)
+
+// getMetricsHandler 流程中记录指标的 handler，重放时不会重复记录
+func (st *dslState) getMetricsHandler(ctx workflow.Context, tags map[string]string) client.MetricsHandler {
+	allTags := map[string]string{
+		MetricTagTaskQueue: workflow.GetInfo(ctx).TaskQueueName,
+		MetricTagDslName:   st.dslName,
+	}
+	for key, val := range tags {
+		allTags[key] = val
+	}
+	return workflow.GetMetricsHandler(ctx).WithTags(allTags)
+}
+
+// getMetricOutcome 根据错误得到结果
+func getMetricOutcome(err error) string {
+	if err != nil {
+		return MetricOutcomeFailed
+	}
+	return MetricOutcomeCompleted
+}
+
+// recordStepMetrics 记录一个activity的耗时和结果
+func recordStepMetrics(ctx workflow.Context, templateName string, outcome string, startTime time.Time) {
+	st := getDslState(ctx)
+	if st == nil {
+		return
+	}
+	handler := st.getMetricsHandler(ctx, map[string]string{
+		MetricTagTemplate: templateName,
+		MetricTagOutcome:  outcome,
+	})
+	handler.Counter(MetricStepTotal).Inc(1)
+	if !startTime.IsZero() {
+		handler.Timer(MetricStepDuration).Record(workflow.Now(ctx).Sub(startTime))
+	}
+}
+
+// recordWorkflowMetrics 记录流程的耗时和结果
+func (st *dslState) recordWorkflowMetrics(ctx workflow.Context, err error) {
+	handler := st.getMetricsHandler(ctx, map[string]string{
+		MetricTagOutcome: getMetricOutcome(err),
+	})
+	handler.Counter(MetricWorkflowTotal).Inc(1)
+	handler.Timer(MetricWorkflowDuration).Record(workflow.Now(ctx).Sub(workflow.GetInfo(ctx).WorkflowStartTime))
+}
+
+// recordWaitMetrics 记录等待的耗时，outcome 为空时不设置结果标签
+func (st *dslState) recordWaitMetrics(ctx workflow.Context, kind string, outcome string, startTime time.Time) {
+	tags := map[string]string{
+		MetricTagWaitKind: kind,
+	}
+	if outcome != "" {
+		tags[MetricTagOutcome] = outcome
+	}
+	st.getMetricsHandler(ctx, tags).Timer(MetricWaitDuration).Record(workflow.Now(ctx).Sub(startTime))
+}
+
+type metricsInterceptor struct {
+	interceptor.InterceptorBase
+}
+
+// NewMetricsInterceptor 记录activity重试次数的拦截器，设置到client后worker也会使用
+// 流程中执行activity时在header中记录流程定义的名字，worker上重试时按模板和流程定义的名字记录，activity的日志也会带上
+func NewMetricsInterceptor() interceptor.Interceptor {
+	return new(metricsInterceptor)
+}
+
+func (m *metricsInterceptor) InterceptActivity(_ context.Context,
+	next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	i := new(metricsActivityInbound)
+	i.Next = next
+	return i
+}
+
+func (m *metricsInterceptor) InterceptWorkflow(_ workflow.Context,
+	next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	i := new(metricsWorkflowInbound)
+	i.Next = next
+	return i
+}
+
+type metricsWorkflowInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+}
+
+func (w *metricsWorkflowInbound) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	o := new(metricsWorkflowOutbound)
+	o.Next = outbound
+	return w.Next.Init(o)
+}
+
+type metricsWorkflowOutbound struct {
+	interceptor.WorkflowOutboundInterceptorBase
+}
+
+func (o *metricsWorkflowOutbound) ExecuteActivity(ctx workflow.Context, activityType string, args ...interface{}) workflow.Future {
+	header := interceptor.WorkflowHeader(ctx)
+	if st := getDslState(ctx); st != nil && st.dslName != "" && header != nil {
+		if payload, err := converter.GetDefaultDataConverter().ToPayload(st.dslName); err == nil {
+			header[activity.DslNameHeader] = payload
+		}
+	}
+	return o.Next.ExecuteActivity(ctx, activityType, args...)
+}
+
+type metricsActivityInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+}
+
+// ExecuteActivity 流程定义的名字设置到ctx中，activity的日志使用，不是第一次执行时记录重试
+func (a *metricsActivityInbound) ExecuteActivity(ctx context.Context, in *interceptor.ExecuteActivityInput) (interface{}, error) {
+	dslName := ""
+	if payload, ok := interceptor.Header(ctx)[activity.DslNameHeader]; ok {
+		_ = converter.GetDefaultDataConverter().FromPayload(payload, &dslName)
+	}
+	if dslName != "" {
+		ctx = activity.WithDslName(ctx, dslName)
+	}
+	info := act.GetInfo(ctx)
+	if info.Attempt > 1 {
+		act.GetMetricsHandler(ctx).WithTags(map[string]string{
+			MetricTagTaskQueue: info.TaskQueue,
+			MetricTagTemplate:  strings.TrimPrefix(info.ActivityType.Name, info.TaskQueue+"/"),
+			MetricTagDslName:   dslName,
+		}).Counter(MetricStepRetries).Inc(1)
+	}
+	return a.Next.ExecuteActivity(ctx, in)
+}
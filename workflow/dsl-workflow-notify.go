@@ -0,0 +1,100 @@
+package workflow
+
+import (
+	cmap "github.com/orcaman/concurrent-map"
+	"github.com/tianlin0/plat-lib/conv"
+	"github.com/tianlin0/temporal/activity"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"time"
+)
+
+const (
+	NotifyStatusCompleted = "completed"
+	NotifyStatusFailed    = "failed"
+
+	defaultNotifyMaxAttempts = 5
+)
+
+type (
+	// Notification 流程结束时发送的webhook通知，通过内置的 dsl-notify-webhook activity 发送，失败会重试
+	Notification struct {
+		Url         string            `json:"url,omitempty"`         //可以使用变量，如：{{variables.callbackUrl}}
+		SecretEnv   string            `json:"secretEnv,omitempty"`   //签名密钥所在的环境变量名，为空则不签名
+		Headers     map[string]string `json:"headers,omitempty"`     //额外的请求头
+		MaxAttempts int32             `json:"maxAttempts,omitempty"` //最多发送次数，默认5次
+	}
+
+	// NotifyPayload 通知的内容
+	NotifyPayload struct {
+		WorkflowId string                 `json:"workflowId"`
+		RunId      string                 `json:"runId"`
+		DslName    string                 `json:"dslName,omitempty"`
+		Status     string                 `json:"status"` //completed、failed
+		Responses  map[string]interface{} `json:"responses,omitempty"`
+		Failure    string                 `json:"failure,omitempty"` //失败原因
+		CloseTime  time.Time              `json:"closeTime"`
+	}
+)
+
+// notifyCompletion 发送流程结束的通知，使用断开的ctx，流程被取消时也会发送；发送失败不影响流程的结果
+func notifyCompletion(ctx workflow.Context, st *dslState, notifications []*Notification,
+	variables map[string]interface{}, responses map[string]interface{}, runErr error) {
+	ctx, _ = workflow.NewDisconnectedContext(ctx)
+	workflowInfo := workflow.GetInfo(ctx)
+
+	payload := &NotifyPayload{
+		WorkflowId: workflowInfo.WorkflowExecution.ID,
+		RunId:      workflowInfo.WorkflowExecution.RunID,
+		DslName:    st.dslName,
+		Status:     NotifyStatusCompleted,
+		Responses:  responses,
+		CloseTime:  workflow.Now(ctx),
+	}
+	if runErr != nil {
+		payload.Status = NotifyStatusFailed
+		payload.Failure = runErr.Error()
+	}
+
+	//通知的配置中可以使用变量
+	bindings := cmap.New()
+	bindings.Set(activity.Variables, variables)
+	if err := New().ReplaceAllByBindings(&notifications, bindings); err != nil {
+		getLogger(ctx).Error("DslWorkflow notify replace error:", err)
+	}
+
+	ac := activity.New()
+	activityName := ac.GetActivityName(workflowInfo.TaskQueueName, activity.NotifyWebhookTemplate)
+	for _, one := range notifications {
+		maxAttempts := one.MaxAttempts
+		if maxAttempts <= 0 {
+			maxAttempts = defaultNotifyMaxAttempts
+		}
+		notifyCtx := workflow.WithActivityOptions(ctx, workflow.ActivityOptions{
+			StartToCloseTimeout: time.Minute,
+			RetryPolicy: &temporal.RetryPolicy{
+				InitialInterval:    time.Second,
+				BackoffCoefficient: 2,
+				MaximumInterval:    time.Minute,
+				MaximumAttempts:    maxAttempts,
+			},
+		})
+		req := &activity.WebhookRequest{
+			Url:       one.Url,
+			SecretEnv: one.SecretEnv,
+			Headers:   one.Headers,
+		}
+		if err := conv.Unmarshal(payload, &req.Payload); err != nil {
+			getLogger(ctx).Error("DslWorkflow notify payload error:", err)
+			continue
+		}
+		param := make(map[string]interface{})
+		if err := conv.Unmarshal(req, &param); err != nil {
+			getLogger(ctx).Error("DslWorkflow notify param error:", err)
+			continue
+		}
+		if err := workflow.ExecuteActivity(notifyCtx, activityName, param).Get(notifyCtx, nil); err != nil {
+			getLogger(ctx).Error("DslWorkflow notify error:", one.Url, err)
+		}
+	}
+}
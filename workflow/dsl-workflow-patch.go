@@ -0,0 +1,242 @@
+package workflow
+
+import (
+	"fmt"
+	cmap "github.com/orcaman/concurrent-map"
+	"github.com/tianlin0/plat-lib/cond"
+	"github.com/tianlin0/plat-lib/conv"
+	"github.com/tianlin0/temporal/activity"
+	"go.temporal.io/sdk/workflow"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	PatchVariablesUpdateName = "dsl-patch-variables" //修改运行中流程的变量和还未执行的activity参数
+	PatchHistoryQueryName    = "dsl-patch-history"   //查询所有的修改记录
+	PatchHistoryMemoKey      = "dslPatches"          //修改记录同时写入memo，流程结束后也可以查看
+)
+
+type (
+	// VariablesPatch 对运行中流程的修改
+	VariablesPatch struct {
+		Variables map[string]interface{}            `json:"variables,omitempty"` //合并到 variables 中的变量
+		Arguments map[string]map[string]interface{} `json:"arguments,omitempty"` //activity id 对应需要覆盖的参数
+		Operator  string                            `json:"operator,omitempty"`  //操作人
+		Reason    string                            `json:"reason,omitempty"`    //修改原因
+	}
+
+	// PatchRecord 一次修改的记录
+	PatchRecord struct {
+		VariablesPatch
+		Time  time.Time `json:"time"`
+		Steps []string  `json:"steps"` //受影响的activity id
+	}
+
+	// PatchResult 修改后还未执行的activity的实际参数
+	PatchResult struct {
+		Arguments map[string]map[string]interface{} `json:"arguments"`
+	}
+)
+
+// setSource 保存原始的流程定义，修改变量时需要根据原始定义重新计算参数
+func (st *dslState) setSource(source *DslWorkflow, live *DslWorkflow, bindings cmap.ConcurrentMap) {
+	st.source = source
+	st.live = live
+	st.bindings = bindings
+}
+
+// registerPatchHandlers 注册修改变量的update和查询修改记录的query
+func (st *dslState) registerPatchHandlers(ctx workflow.Context) error {
+	err := workflow.SetQueryHandler(ctx, PatchHistoryQueryName, func() ([]*PatchRecord, error) {
+		return st.patchHistory, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return workflow.SetUpdateHandlerWithOptions(ctx, PatchVariablesUpdateName,
+		func(ctx workflow.Context, patch *VariablesPatch) (*PatchResult, error) {
+			return st.applyPatch(ctx, patch)
+		},
+		workflow.UpdateHandlerOptions{
+			Validator: func(patch *VariablesPatch) error {
+				return st.checkPatch(patch)
+			},
+		})
+}
+
+// checkPatch 只能修改还未开始执行的activity
+func (st *dslState) checkPatch(patch *VariablesPatch) error {
+	if patch == nil || (len(patch.Variables) == 0 && len(patch.Arguments) == 0) {
+		return fmt.Errorf("patch is empty")
+	}
+	if st.source == nil {
+		return fmt.Errorf("workflow is not ready for patch")
+	}
+	for id := range patch.Arguments {
+		if ok, _ := cond.Contains(st.stepIds, id); !ok {
+			return fmt.Errorf("step %s not found", id)
+		}
+		if st.startedSteps[id] {
+			return fmt.Errorf("step %s has started", id)
+		}
+	}
+	//variables 中以activity id为key的参数，只对该activity生效
+	for key := range patch.Variables {
+		if ok, _ := cond.Contains(st.stepIds, key); ok && st.startedSteps[key] {
+			return fmt.Errorf("step %s has started", key)
+		}
+	}
+
+	//重新计算参数失败时拒绝，不会修改流程的状态
+	ps, err := st.getPatchedState(patch)
+	if err != nil {
+		return err
+	}
+	if len(patch.Variables) == 0 {
+		return nil
+	}
+	//修改的变量只影响已经开始执行的activity时不会生效，需要拒绝
+	current, err := st.computeArguments(st.source.Variables)
+	if err != nil {
+		return err
+	}
+	startedList := make([]string, 0)
+	for id, args := range ps.arguments {
+		if id == "" || conv.String(args) == conv.String(current[id]) {
+			continue
+		}
+		if !st.startedSteps[id] {
+			return nil
+		}
+		startedList = append(startedList, id)
+	}
+	if len(startedList) > 0 {
+		sort.Strings(startedList)
+		return fmt.Errorf("variables only affect started steps: %s", strings.Join(startedList, ","))
+	}
+	return nil
+}
+
+// patchedState 修改后的变量和参数，计算成功后才替换流程的状态
+type patchedState struct {
+	variables       map[string]interface{}
+	argumentPatches map[string]map[string]interface{}
+	arguments       map[string]map[string]interface{} //根据原始定义和新的变量重新计算的参数
+}
+
+// getPatchedState 在副本上合并修改并重新计算参数，不修改流程的状态
+func (st *dslState) getPatchedState(patch *VariablesPatch) (*patchedState, error) {
+	ps := &patchedState{
+		variables:       make(map[string]interface{}),
+		argumentPatches: make(map[string]map[string]interface{}),
+	}
+	for key, val := range st.source.Variables {
+		ps.variables[key] = val
+	}
+	for key, val := range patch.Variables {
+		ps.variables[key] = val
+	}
+	for id, args := range st.argumentPatches {
+		ps.argumentPatches[id] = make(map[string]interface{})
+		for key, val := range args {
+			ps.argumentPatches[id][key] = val
+		}
+	}
+	for id, args := range patch.Arguments {
+		if _, ok := ps.argumentPatches[id]; !ok {
+			ps.argumentPatches[id] = make(map[string]interface{})
+		}
+		for key, val := range args {
+			ps.argumentPatches[id][key] = val
+		}
+	}
+
+	var err error
+	if ps.arguments, err = st.computeArguments(ps.variables); err != nil {
+		return nil, err
+	}
+	return ps, nil
+}
+
+// computeArguments 根据原始定义、重新执行时的seed和变量计算所有activity的参数，与流程启动时的计算方式相同
+func (st *dslState) computeArguments(variables map[string]interface{}) (map[string]map[string]interface{}, error) {
+	newDsl := new(DslWorkflow)
+	if err := conv.Unmarshal(st.source, newDsl); err != nil {
+		return nil, err
+	}
+	newDsl.Variables = make(map[string]interface{}, len(variables))
+	for key, val := range variables {
+		newDsl.Variables[key] = val
+	}
+	bindings := cmap.New()
+	bindings.Set(activity.Variables, newDsl.Variables)
+	bindings, err := st.seedBindings(bindings)
+	if err != nil {
+		return nil, err
+	}
+	if newDsl, err = newDsl.SetVariablesToAll(bindings); err != nil {
+		return nil, err
+	}
+	if err = newDsl.Validate(); err != nil {
+		return nil, err
+	}
+	arguments := make(map[string]map[string]interface{})
+	for _, one := range newDsl.getOneActivityList(&newDsl.Root) {
+		arguments[one.Id] = one.Arguments
+	}
+	return arguments, nil
+}
+
+// applyPatch 在副本上重新计算还未执行的activity的参数，全部成功后再修改流程的状态并记录修改
+func (st *dslState) applyPatch(ctx workflow.Context, patch *VariablesPatch) (*PatchResult, error) {
+	ps, err := st.getPatchedState(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PatchResult{Arguments: make(map[string]map[string]interface{})}
+	steps := make([]string, 0)
+	liveList := make([]*ActivityInvocation, 0)
+	for _, one := range st.live.getOneActivityList(&st.live.Root) {
+		if one.Id == "" || st.startedSteps[one.Id] {
+			continue
+		}
+		args := make(map[string]interface{})
+		for key, val := range ps.arguments[one.Id] {
+			args[key] = val
+		}
+		for key, val := range ps.argumentPatches[one.Id] {
+			args[key] = val
+		}
+		result.Arguments[one.Id] = args
+		liveList = append(liveList, one)
+		if ok, _ := cond.Contains(steps, one.Id); !ok {
+			steps = append(steps, one.Id)
+		}
+	}
+
+	patchHistory := append(append(make([]*PatchRecord, 0, len(st.patchHistory)+1), st.patchHistory...), &PatchRecord{
+		VariablesPatch: *patch,
+		Time:           workflow.Now(ctx),
+		Steps:          steps,
+	})
+	if err = workflow.UpsertMemo(ctx, map[string]interface{}{
+		PatchHistoryMemoKey: patchHistory,
+	}); err != nil {
+		return nil, err
+	}
+
+	//全部计算成功后再修改状态
+	for _, one := range liveList {
+		one.Arguments = result.Arguments[one.Id]
+	}
+	st.source.Variables = ps.variables
+	st.argumentPatches = ps.argumentPatches
+	st.bindings.Set(activity.Variables, st.source.Variables)
+	st.patchHistory = patchHistory
+	getLogger(ctx).Info("DslWorkflow patch variables:", conv.String(patch))
+	return result, nil
+}
@@ -0,0 +1,87 @@
+package workflow
+
+import (
+	"fmt"
+	cmap "github.com/orcaman/concurrent-map"
+	"go.temporal.io/sdk/workflow"
+	"time"
+)
+
+const (
+	defaultPollInterval = 10 * time.Second
+)
+
+// Execute 轮询执行activity，直到 Until 条件满足
+// 两次执行之间使用 workflow.Sleep 等待，等待期间不会占用activity的执行槽位
+func (p *PollInvocation) Execute(ctx workflow.Context, bindings cmap.ConcurrentMap) (cmap.ConcurrentMap, error) {
+	if err := p.check(); err != nil {
+		return bindings, err
+	}
+
+	interval, _ := parseDuration(p.Interval, defaultPollInterval)
+	maxInterval, _ := parseDuration(p.MaxInterval, 0)
+	maxDuration, _ := parseDuration(p.MaxDuration, 0)
+	coefficient := p.BackoffCoefficient
+	if coefficient < 1 {
+		coefficient = 1
+	}
+
+	cm := New()
+	startTime := workflow.Now(ctx)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		bindings, err = p.Activity.Execute(ctx, bindings)
+		if err != nil {
+			return bindings, err
+		}
+
+		ready, err := cm.ShouldExecute(p.Until, bindings)
+		if err != nil {
+			return bindings, err
+		}
+		if ready {
+			return bindings, nil
+		}
+
+		if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+			return bindings, fmt.Errorf("poll %s not ready after %d attempts", p.Activity.Id, attempt)
+		}
+		if maxDuration > 0 && workflow.Now(ctx).Sub(startTime)+interval > maxDuration {
+			return bindings, fmt.Errorf("poll %s not ready after %s", p.Activity.Id, p.MaxDuration)
+		}
+
+		if err = workflow.Sleep(ctx, interval); err != nil {
+			return bindings, err
+		}
+
+		interval = time.Duration(float64(interval) * coefficient)
+		if maxInterval > 0 && interval > maxInterval {
+			interval = maxInterval
+		}
+	}
+}
+
+// check 检查轮询的配置
+func (p *PollInvocation) check() error {
+	if p.Activity == nil {
+		return fmt.Errorf("poll activity is null")
+	}
+	if p.Until == "" {
+		return fmt.Errorf("poll %s until is empty", p.Activity.Id)
+	}
+	for _, one := range []string{p.Interval, p.MaxInterval, p.MaxDuration} {
+		if _, err := parseDuration(one, 0); err != nil {
+			return fmt.Errorf("poll %s duration error: %s", p.Activity.Id, err.Error())
+		}
+	}
+	return nil
+}
+
+// parseDuration 解析时间间隔，为空时返回默认值
+func parseDuration(d string, defaultDuration time.Duration) (time.Duration, error) {
+	if d == "" {
+		return defaultDuration, nil
+	}
+	return time.ParseDuration(d)
+}
@@ -0,0 +1,139 @@
+package workflow
+
+import (
+	"fmt"
+	cmap "github.com/orcaman/concurrent-map"
+	"github.com/tianlin0/plat-lib/conv"
+	"github.com/tianlin0/plat-lib/templates"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SearchAttributeTypeKeyword     = "keyword"
+	SearchAttributeTypeKeywordList = "keywordList" //多个值用逗号分隔
+	SearchAttributeTypeText        = "text"
+	SearchAttributeTypeInt         = "int"
+	SearchAttributeTypeDouble      = "double"
+	SearchAttributeTypeBool        = "bool"
+	SearchAttributeTypeDatetime    = "datetime" //RFC3339格式
+)
+
+// SearchAttributeDefine 需要写入 search attribute 的值，需要在temporal中预先注册
+// Value 可以使用变量或者activity的返回值，如：{{variables.projectName}}、{{deploy.responses.status}}
+// 流程启动时设置一次，之后每个activity执行完成后，值有变化时重新设置；还取不到值时跳过
+type SearchAttributeDefine struct {
+	Name  string `json:"name,omitempty"`
+	Type  string `json:"type,omitempty"` //keyword、keywordList、text、int、double、bool、datetime，默认keyword
+	Value string `json:"value,omitempty"`
+}
+
+// check 检查search attribute的配置
+func (s *SearchAttributeDefine) check() error {
+	if s.Name == "" {
+		return fmt.Errorf("search attribute name is empty")
+	}
+	if s.Value == "" {
+		return fmt.Errorf("search attribute %s value is empty", s.Name)
+	}
+	switch s.Type {
+	case "", SearchAttributeTypeKeyword, SearchAttributeTypeKeywordList, SearchAttributeTypeText,
+		SearchAttributeTypeInt, SearchAttributeTypeDouble, SearchAttributeTypeBool, SearchAttributeTypeDatetime:
+		return nil
+	}
+	return fmt.Errorf("search attribute %s type %s not support", s.Name, s.Type)
+}
+
+// getUpdate 根据类型将值转换为search attribute的修改
+func (s *SearchAttributeDefine) getUpdate(val string) (temporal.SearchAttributeUpdate, error) {
+	switch s.Type {
+	case "", SearchAttributeTypeKeyword:
+		return temporal.NewSearchAttributeKeyKeyword(s.Name).ValueSet(val), nil
+	case SearchAttributeTypeText:
+		return temporal.NewSearchAttributeKeyString(s.Name).ValueSet(val), nil
+	case SearchAttributeTypeKeywordList:
+		valList := make([]string, 0)
+		for _, one := range strings.Split(val, ",") {
+			if one = strings.TrimSpace(one); one != "" {
+				valList = append(valList, one)
+			}
+		}
+		return temporal.NewSearchAttributeKeyKeywordList(s.Name).ValueSet(valList), nil
+	case SearchAttributeTypeInt:
+		i, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		return temporal.NewSearchAttributeKeyInt64(s.Name).ValueSet(i), nil
+	case SearchAttributeTypeDouble:
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, err
+		}
+		return temporal.NewSearchAttributeKeyFloat64(s.Name).ValueSet(f), nil
+	case SearchAttributeTypeBool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return nil, err
+		}
+		return temporal.NewSearchAttributeKeyBool(s.Name).ValueSet(b), nil
+	case SearchAttributeTypeDatetime:
+		t, err := time.Parse(time.RFC3339, val)
+		if err != nil {
+			return nil, err
+		}
+		return temporal.NewSearchAttributeKeyTime(s.Name).ValueSet(t), nil
+	}
+	return nil, fmt.Errorf("search attribute %s type %s not support", s.Name, s.Type)
+}
+
+// upsertSearchAttributes 根据当前的bindings计算所有search attribute的值，只设置有变化的部分
+func (st *dslState) upsertSearchAttributes(ctx workflow.Context, bindings cmap.ConcurrentMap) error {
+	if len(st.searchAttributes) == 0 {
+		return nil
+	}
+
+	bindingsMap := conv.ToKeyListFromMap(New().ChangeConcurrentMapToMap(bindings))
+	updateList := make([]temporal.SearchAttributeUpdate, 0)
+	for _, one := range st.searchAttributes {
+		val, err := templates.NewTemplate(one.Value).Replace(bindingsMap)
+		if err != nil {
+			return err
+		}
+		//还有取不到的值，等之后的activity执行完再设置
+		if val == "" || strings.Contains(val, "{{") {
+			continue
+		}
+		if old, ok := st.searchAttributeValues[one.Name]; ok && old == val {
+			continue
+		}
+		update, err := one.getUpdate(val)
+		if err != nil {
+			return fmt.Errorf("search attribute %s value %s error: %s", one.Name, val, err.Error())
+		}
+		st.searchAttributeValues[one.Name] = val
+		updateList = append(updateList, update)
+	}
+	if len(updateList) == 0 {
+		return nil
+	}
+	return workflow.UpsertTypedSearchAttributes(ctx, updateList...)
+}
+
+// getStartSearchAttributes 已经设置的search attribute，启动 onExit: return 的后续子流程时带上，子流程启动后就可以查询
+func (st *dslState) getStartSearchAttributes() temporal.SearchAttributes {
+	updateList := make([]temporal.SearchAttributeUpdate, 0, len(st.searchAttributeValues))
+	for _, one := range st.searchAttributes {
+		val, ok := st.searchAttributeValues[one.Name]
+		if !ok {
+			continue
+		}
+		if update, err := one.getUpdate(val); err == nil {
+			updateList = append(updateList, update)
+		}
+	}
+	return temporal.NewSearchAttributes(updateList...)
+}
@@ -0,0 +1,67 @@
+package workflow
+
+import (
+	cmap "github.com/orcaman/concurrent-map"
+	"github.com/tianlin0/plat-lib/cond"
+	"github.com/tianlin0/temporal/activity"
+	"go.temporal.io/sdk/workflow"
+)
+
+// StepSeed 重新执行流程时，之前已经执行成功的activity的参数和返回值
+// 有seed的activity不会再真正执行，直接使用记录的返回值，和跳过的处理方式一样
+type StepSeed struct {
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Responses map[string]interface{} `json:"responses,omitempty"`
+}
+
+// setSeeds 将seed设置为需要跳过的activity，不在当前流程中的seed留给子流程
+func (st *dslState) setSeeds(seeds map[string]*StepSeed) {
+	st.seeds = seeds
+	for id, seed := range seeds {
+		if ok, _ := cond.Contains(st.stepIds, id); !ok || seed == nil {
+			continue
+		}
+		responses := seed.Responses
+		if responses == nil {
+			responses = map[string]interface{}{}
+		}
+		st.skipSteps[id] = responses
+	}
+}
+
+// seedBindings 将所有seed的参数和返回值写入bindings，后面的activity可以直接引用
+func (st *dslState) seedBindings(bindings cmap.ConcurrentMap) (cmap.ConcurrentMap, error) {
+	comm := New()
+	var err error
+	for id, seed := range st.seeds {
+		if seed == nil {
+			continue
+		}
+		if len(seed.Arguments) > 0 {
+			if bindings, err = comm.ExtendToBindings(bindings, seed.Arguments, id, activity.Arguments); err != nil {
+				return bindings, err
+			}
+		}
+		if len(seed.Responses) > 0 {
+			if bindings, err = comm.ExtendToBindings(bindings, seed.Responses, id, activity.Responses); err != nil {
+				return bindings, err
+			}
+		}
+	}
+	return bindings, nil
+}
+
+// getSeedArguments 跳过的activity有seed时，使用记录的参数，不再重新计算
+func getSeedArguments(ctx workflow.Context, id string, fakeOutput map[string]interface{}) map[string]interface{} {
+	if fakeOutput == nil {
+		return nil
+	}
+	st := getDslState(ctx)
+	if st == nil {
+		return nil
+	}
+	if seed, ok := st.seeds[id]; ok && seed != nil && seed.Arguments != nil {
+		return seed.Arguments
+	}
+	return nil
+}
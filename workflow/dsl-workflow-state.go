@@ -0,0 +1,107 @@
+package workflow
+
+import (
+	"fmt"
+	cmap "github.com/orcaman/concurrent-map"
+	"go.temporal.io/sdk/workflow"
+)
+
+type contextKey string
+
+const (
+	dslStateContextKey contextKey = "dsl-state"
+
+	activityIdChangeId    = "dsl-activity-id"    //使用DSL中的id作为activity id的版本标记，兼容之前启动的流程
+	continueChildChangeId = "dsl-continue-child" //onExit: return 等待后续子流程启动后再结束的版本标记，兼容之前启动的流程
+)
+
+// dslState 一次 DslWorkflow 执行过程中的运行状态，通过ctx传递给所有的语句
+// signal、update、query 的处理方法都修改或读取这里的内容
+type dslState struct {
+	dslName string //流程定义的名字，子流程使用相同的名字
+
+	pendingApprovals map[string]*PendingApproval  //等待中的审批
+	approvalResults  map[string]*ApprovalDecision //已经收到的审批结果
+
+	stepIds        []string                          //流程中所有的activity id
+	waitOnFailure  bool                              //执行失败时是否等待人工处理
+	paused         bool                              //是否暂停
+	skipSteps      map[string]map[string]interface{} //需要跳过的activity，以及跳过时使用的返回值
+	retrySteps     map[string]bool                   //需要重试的失败activity
+	failedSteps    map[string]string                 //执行失败，等待人工处理的activity，以及失败原因
+	completedSteps map[string]bool                   //已经执行成功的activity
+	startedSteps   map[string]bool                   //已经开始执行的activity，不能再修改参数
+
+	source          *DslWorkflow                      //原始的流程定义，变量还未替换
+	live            *DslWorkflow                      //正在执行的流程定义
+	bindings        cmap.ConcurrentMap                //根bindings
+	argumentPatches map[string]map[string]interface{} //通过update修改的activity参数
+	patchHistory    []*PatchRecord                    //修改记录
+
+	searchAttributes      []*SearchAttributeDefine //需要设置的search attribute
+	searchAttributeValues map[string]string        //已经设置的search attribute的值
+
+	useDslActivityId bool           //是否使用DSL中的id作为activity id
+	activityIdCount  map[string]int //每个id已经执行的次数
+
+	seeds map[string]*StepSeed //重新执行时已经执行成功的activity
+
+	continued bool //已经启动了 onExit: return 的后续子流程，流程结束的通知和指标由子流程记录
+}
+
+// newDslState 新建运行状态，设置到ctx中，并注册所有的处理方法
+func newDslState(ctx workflow.Context, dslWorkflow *DslWorkflow) (workflow.Context, *dslState, error) {
+	st := &dslState{
+		dslName:          dslWorkflow.Name,
+		pendingApprovals: make(map[string]*PendingApproval),
+		approvalResults:  make(map[string]*ApprovalDecision),
+		stepIds:          dslWorkflow.getAllWorkflowIdList(&dslWorkflow.Root, dslWorkflow.Activities),
+		waitOnFailure:    dslWorkflow.WaitOnFailure,
+		skipSteps:        make(map[string]map[string]interface{}),
+		retrySteps:       make(map[string]bool),
+		failedSteps:      make(map[string]string),
+		completedSteps:   make(map[string]bool),
+		startedSteps:     make(map[string]bool),
+		argumentPatches:  make(map[string]map[string]interface{}),
+		patchHistory:     make([]*PatchRecord, 0),
+
+		searchAttributeValues: make(map[string]string),
+
+		useDslActivityId: workflow.GetVersion(ctx, activityIdChangeId, workflow.DefaultVersion, 1) == 1,
+		activityIdCount:  make(map[string]int),
+	}
+	st.setSeeds(dslWorkflow.Seeds)
+	ctx = workflow.WithValue(ctx, dslStateContextKey, st)
+
+	if err := st.registerApprovalHandlers(ctx); err != nil {
+		return ctx, st, err
+	}
+	if err := st.registerControlHandlers(ctx); err != nil {
+		return ctx, st, err
+	}
+	if err := st.registerPatchHandlers(ctx); err != nil {
+		return ctx, st, err
+	}
+	return ctx, st, nil
+}
+
+// getDslState 从ctx中获取运行状态，不是在 DslWorkflow 中执行时返回nil
+func getDslState(ctx workflow.Context) *dslState {
+	if st, ok := ctx.Value(dslStateContextKey).(*dslState); ok {
+		return st
+	}
+	return nil
+}
+
+// getActivityId 获取执行activity时使用的activity id，第一次执行为DSL中的id，之后为 id#2、id#3...
+// 返回空表示使用temporal默认生成的id
+func (st *dslState) getActivityId(id string) string {
+	if !st.useDslActivityId || id == "" {
+		return ""
+	}
+	st.activityIdCount[id]++
+	if count := st.activityIdCount[id]; count > 1 {
+		return fmt.Sprintf("%s#%d", id, count)
+	}
+	return id
+}
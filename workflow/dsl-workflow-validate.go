@@ -0,0 +1,101 @@
+package workflow
+
+import (
+	"fmt"
+	"github.com/tianlin0/plat-lib/cond"
+	"github.com/tianlin0/plat-lib/conv"
+	"regexp"
+)
+
+var (
+	// referenceRegexp 匹配 {{id.xxx}} 中的 id
+	referenceRegexp = regexp.MustCompile(`\{\{([^\.\}\s]+)\.`)
+)
+
+// Validate 检查流程定义是否合法
+// 并行的分支之间不能有相同的id，也不能引用兄弟分支的值，因为兄弟分支的执行结果要在全部结束后才会合并
+func (t *DslWorkflow) Validate() error {
+	for _, one := range t.SearchAttributes {
+		if err := one.check(); err != nil {
+			return err
+		}
+	}
+	return t.validateStatement(&t.Root)
+}
+
+func (t *DslWorkflow) validateStatement(b *Statement) error {
+	if b == nil {
+		return nil
+	}
+
+	if b.Poll != nil {
+		if err := b.Poll.check(); err != nil {
+			return err
+		}
+	}
+
+	if len(b.Parallel) > 0 {
+		if err := t.validateParallel(b.Parallel); err != nil {
+			return err
+		}
+	}
+
+	if b.Approval != nil {
+		if err := b.Approval.check(); err != nil {
+			return err
+		}
+	}
+
+	for _, one := range b.getChildStatementList() {
+		if err := t.validateStatement(one); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *DslWorkflow) validateParallel(p Parallel) error {
+	branchIds := make([][]string, len(p))
+	for i, one := range p {
+		branchIds[i] = p.getBranchIdList(one)
+	}
+
+	for i, one := range p {
+		//分支之间不能有相同的id
+		for _, id := range branchIds[i] {
+			for j := i + 1; j < len(branchIds); j++ {
+				if ok, _ := cond.Contains(branchIds[j], id); ok {
+					return fmt.Errorf("parallel branch %d and %d have the same id: %s", i, j, id)
+				}
+			}
+		}
+
+		//不能引用兄弟分支的值
+		for _, ref := range getReferenceIdList(one) {
+			if ok, _ := cond.Contains(branchIds[i], ref); ok {
+				continue
+			}
+			for j, ids := range branchIds {
+				if j == i {
+					continue
+				}
+				if ok, _ := cond.Contains(ids, ref); ok {
+					return fmt.Errorf("parallel branch %d references %s of sibling branch %d", i, ref, j)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// getReferenceIdList 获取语句中所有 {{id.xxx}} 引用到的id
+func getReferenceIdList(b *Statement) []string {
+	refList := make([]string, 0)
+	matches := referenceRegexp.FindAllStringSubmatch(conv.String(b), -1)
+	for _, one := range matches {
+		if ok, _ := cond.Contains(refList, one[1]); !ok {
+			refList = append(refList, one[1])
+		}
+	}
+	return refList
+}
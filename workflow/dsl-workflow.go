@@ -1,11 +1,10 @@
 package workflow
 
 import (
-	"context"
 	"encoding/base64"
 	"fmt"
 	cmap "github.com/orcaman/concurrent-map"
-	"github.com/tianlin0/plat-lib/logs"
+	"github.com/tianlin0/plat-lib/conv"
 	"github.com/tianlin0/temporal/activity"
 	"go.temporal.io/sdk/temporal"
 	"go.temporal.io/sdk/workflow"
@@ -14,6 +13,10 @@ import (
 	"time"
 )
 
+const (
+	DslNameMemoKey = "dslName" //流程定义的名字在memo中的key，提交时和启动子流程时设置
+)
+
 type dslWorkflow struct {
 }
 
@@ -23,7 +26,7 @@ func (c *dslWorkflow) DslWorkflow(ctx workflow.Context, actOption *workflow.Acti
 		return nil, fmt.Errorf("dslWorkflow is null")
 	}
 
-	logs.DefaultLogger().Info("DslWorkflow_actOption:", actOption)
+	getLogger(ctx).Info("DslWorkflow_actOption:", actOption)
 
 	if actOption == nil {
 		actOption = &workflow.ActivityOptions{
@@ -46,23 +49,65 @@ func (c *dslWorkflow) DslWorkflow(ctx workflow.Context, actOption *workflow.Acti
 
 	ctx = workflow.WithActivityOptions(ctx, *actOption)
 
+	//流程运行状态，以及审批、暂停等signal、update、query的处理
+	ctx, st, err := newDslState(ctx, dslWorkflow)
+	if err != nil {
+		return nil, err
+	}
+
+	//执行成功或者失败都需要发送通知，启动了 onExit: return 的后续子流程时由子流程发送
+	notifications := dslWorkflow.Notifications
+	variables := dslWorkflow.Variables
+	retMap, err := c.execute(ctx, st, dslWorkflow)
+	//启动了后续子流程时，流程的耗时和结果由子流程记录，避免重复计数
+	if !st.continued {
+		st.recordWorkflowMetrics(ctx, err)
+	}
+	if len(notifications) > 0 && !st.continued {
+		notifyCompletion(ctx, st, notifications, variables, retMap, err)
+	}
+	return retMap, err
+}
+
+// execute 设置变量后执行流程，返回流程定义中设置的返回值
+func (c *dslWorkflow) execute(ctx workflow.Context, st *dslState, dslWorkflow *DslWorkflow) (map[string]interface{}, error) {
+	var err error
+
 	// 所有参数
 	bindings := cmap.New()
 	if dslWorkflow.Variables != nil {
 		bindings.Set(activity.Variables, dslWorkflow.Variables)
 	}
+	//重新执行时，之前已经执行成功的activity的参数和返回值
+	if bindings, err = st.seedBindings(bindings); err != nil {
+		return nil, err
+	}
+
+	logger := getLogger(ctx)
 
-	logger := logs.DefaultLogger()
-	if newCtx, ok := ctx.(context.Context); ok {
-		logger = logs.CtxLogger(newCtx)
+	//保存原始的定义，运行中修改变量时需要重新计算参数
+	source := new(DslWorkflow)
+	if err = conv.Unmarshal(dslWorkflow, source); err != nil {
+		return nil, err
 	}
 
 	//设置变量到所有流程的参数中
-	dslWorkflow, err := dslWorkflow.SetVariablesToAll(bindings)
+	dslWorkflow, err = dslWorkflow.SetVariablesToAll(bindings)
 	if err != nil {
 		logger.Error("DslWorkflow SetVariablesToAll error:", err)
 	}
 
+	if err = dslWorkflow.Validate(); err != nil {
+		return nil, err
+	}
+	st.setSource(source, dslWorkflow, bindings)
+
+	//启动时设置已经可以取到值的search attribute
+	st.searchAttributes = dslWorkflow.SearchAttributes
+	if err = st.upsertSearchAttributes(ctx, bindings); err != nil {
+		return nil, err
+	}
+
 	ret, err := dslWorkflow.Root.Execute(ctx, bindings)
 	if err != nil {
 		return nil, err
@@ -73,6 +118,7 @@ func (c *dslWorkflow) DslWorkflow(ctx workflow.Context, actOption *workflow.Acti
 		cm := New()
 		retMap, err = cm.GetOutputMap(dslWorkflow.Responses, map[string]interface{}{}, ret)
 		if err != nil {
+			logger.Error("DslWorkflow GetOutputMap error:", err)
 			return nil, err
 		}
 	} else {
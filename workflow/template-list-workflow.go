@@ -6,7 +6,6 @@ import (
 	cmap "github.com/orcaman/concurrent-map"
 	"github.com/tianlin0/plat-lib/cond"
 	"github.com/tianlin0/plat-lib/conv"
-	"github.com/tianlin0/plat-lib/logs"
 	"github.com/tianlin0/plat-lib/templates"
 	"github.com/tianlin0/plat-lib/utils"
 	"github.com/tianlin0/temporal/activity"
@@ -82,6 +81,7 @@ func (t *TemplateStepList) executeOneActivity(ctx workflow.Context,
 
 	args, err := t.makeInputMap(args, arguments)
 	if err != nil {
+		getLogger(ctx).Error("makeInputMap:", err)
 		return nil, err
 	}
 
@@ -156,7 +156,6 @@ func (t *TemplateStepList) makeInputMap(argNames map[string]interface{}, argumen
 	tmp := templates.NewTemplate(allParamStr)
 	allParamStrRet, err := tmp.Replace(argsMapList)
 	if err != nil {
-		logs.DefaultLogger().Error("makeInputMap:", err.Error())
 		return args, err
 	}
 	_ = conv.Unmarshal(allParamStrRet, &args)
@@ -230,7 +229,7 @@ func (t *TemplateStepList) Run(ctx workflow.Context, variable map[string]interfa
 					workflow.GetActivityOptions(ctx),
 					childWorkflow, variable).Get(ctx, nil)
 				if err != nil {
-					logs.DefaultLogger().Error("ExecuteChildWorkflow1:", err)
+					getLogger(ctx).Error("ExecuteChildWorkflow1:", err)
 				}
 				return bindings, nil
 			}